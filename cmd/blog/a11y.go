@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+
+	"be/site"
+)
+
+var checkA11yCommand = command{
+	Help: "build the site, and report what makes its pages hard to use with a screen reader",
+	Setup: func(fs *flag.FlagSet) func([]string) error {
+		configPath := configFlag(fs)
+		profile := profileFlag(fs, "")
+		return func(args []string) error {
+			if len(args) > 0 {
+				return fmt.Errorf("unexpected arguments: %v", args)
+			}
+			cfg, err := site.LoadConfig(*configPath, *profile)
+			if err != nil {
+				return err
+			}
+			blog := site.New(cfg)
+			if err := blog.Build(); err != nil {
+				return err
+			}
+			problems := blog.CheckAccessibility()
+			for _, p := range problems {
+				slog.Warn(p.Error())
+			}
+			if len(problems) > 0 {
+				return fmt.Errorf("%d problem(s) found", len(problems))
+			}
+			return nil
+		}
+	},
+}
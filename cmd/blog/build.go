@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"be/site"
+)
+
+// configFlag registers the -config flag shared by all commands.
+func configFlag(fs *flag.FlagSet) *string {
+	return fs.String("config", "blog.json", "path to the site configuration")
+}
+
+// profileFlag registers the -profile flag shared by all commands, empty
+// selects the profile set in the configuration.
+func profileFlag(fs *flag.FlagSet, def string) *string {
+	return fs.String("profile", def, "named set of configuration overrides to apply (e.g. dev, prod)")
+}
+
+var buildCommand = command{
+	Help: "build the site into the output directory",
+	Setup: func(fs *flag.FlagSet) func([]string) error {
+		configPath := configFlag(fs)
+		profile := profileFlag(fs, "")
+		dev := fs.Bool("dev", false, "development build, publish pages, stylesheets and scripts unbundled and unminified")
+		watch := fs.Bool("watch", false, "keep rebuilding the outputs affected by changes to the sources (the hooks only run for the first build)")
+		dryRun := fs.Bool("dry-run", false, "only report which output files would be created, changed or deleted, without running the hooks")
+		diff := fs.Bool("diff", false, "like -dry-run, and show how the html pages would change")
+		reportJSON := fs.String("report-json", "", "also write the build report (timings, sizes, cache hits) as json to this file")
+		return func(args []string) error {
+			if len(args) > 0 {
+				return fmt.Errorf("unexpected arguments: %v", args)
+			}
+			if *watch && (*dryRun || *diff) {
+				return fmt.Errorf("-watch cannot be combined with -dry-run or -diff")
+			}
+			hooks := !*dryRun && !*diff
+			blog, err := build(*configPath, *profile, *dev, hooks)
+			if err != nil {
+				return err
+			}
+			if !hooks {
+				return reportChanges(blog, *diff)
+			}
+			if err := blog.Write(); err != nil {
+				return err
+			}
+			if err := blog.RunHooks(site.HookPostBuild, ""); err != nil {
+				return err
+			}
+			report := blog.Report(5)
+			if slog.Default().Enabled(context.Background(), slog.LevelInfo) {
+				printReport(os.Stderr, blog.Config.Output, report)
+			}
+			if *reportJSON != "" {
+				bs, err := json.MarshalIndent(report, "", "\t")
+				if err != nil {
+					return err
+				}
+				if err := os.WriteFile(*reportJSON, append(bs, '\n'), 0644); err != nil {
+					return err
+				}
+			}
+			if *watch {
+				watchBuild(blog, *configPath, *profile, *dev)
+			}
+			return nil
+		}
+	},
+}
+
+// build builds the site, after running the pre_build hooks if hooks (the
+// caller runs the post_build ones after writing it).
+func build(configPath, profile string, dev, hooks bool) (*site.Site, error) {
+	cfg, err := site.LoadConfig(configPath, profile)
+	if err != nil {
+		return nil, err
+	}
+	if dev {
+		cfg.Dev = true
+	}
+	blog := site.New(cfg)
+	if hooks {
+		if err := blog.RunHooks(site.HookPreBuild, ""); err != nil {
+			return nil, err
+		}
+	}
+	return blog, blog.Build()
+}
+
+// reportChanges lists what writing the site would change in the output
+// directory, with the diffs of the html pages if showDiff.
+func reportChanges(blog *site.Site, showDiff bool) error {
+	changes, err := blog.Diff()
+	if err != nil {
+		return err
+	}
+	counts := map[site.ChangeKind]int{}
+	for _, c := range changes {
+		counts[c.Kind]++
+		fmt.Printf("%c %s\n", c.Kind, c.Path)
+		if showDiff && path.Ext(c.Path) == ".html" {
+			if err := c.WriteDiff(os.Stdout); err != nil {
+				return err
+			}
+		}
+	}
+	fmt.Printf("%d created, %d changed, %d deleted (not written)\n", counts[site.Created], counts[site.Changed], counts[site.Deleted])
+	return nil
+}
+
+// printReport summarizes the build for humans.
+func printReport(w io.Writer, output string, r site.Report) {
+	fmt.Fprintf(w, "built %d entries into %s: %d pages, %d files, %s in %s\n", r.Entries, output, r.Pages, r.Outputs, formatSize(r.Size), formatDuration(r.Duration))
+	var stages []string
+	for _, st := range r.Stages {
+		stages = append(stages, fmt.Sprintf("%s %s", st.Stage, formatDuration(st.Duration)))
+	}
+	fmt.Fprintf(w, "  stages:  %s\n", strings.Join(stages, ", "))
+	for i, o := range r.Largest {
+		label := ""
+		if i == 0 {
+			label = "largest:"
+		}
+		fmt.Fprintf(w, "  %-8s %8s  %s\n", label, formatSize(o.Size), o.Path)
+	}
+	kinds := make([]string, 0, len(r.Cache))
+	for kind := range r.Cache {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	var lookups []string
+	for _, kind := range kinds {
+		c := r.Cache[kind]
+		lookups = append(lookups, fmt.Sprintf("%s %d/%d", kind, c.Hits, c.Hits+c.Misses))
+	}
+	if len(lookups) > 0 {
+		fmt.Fprintf(w, "  cache:   %.0f%% hits (%s)\n", 100*r.CacheHitRate, strings.Join(lookups, ", "))
+	}
+}
+
+// formatDuration rounds d to about three significant digits.
+func formatDuration(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond).String()
+	default:
+		return d.Round(time.Microsecond).String()
+	}
+}
+
+func formatSize(n int) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
+
+// watchBuild rebuilds and writes the outputs affected by every change to the
+// sources, everything if the config changed. It never returns.
+func watchBuild(blog *site.Site, configPath, profile string, dev bool) {
+	stale := false // after a failed incremental rebuild
+	site.Watch(func() []string {
+		return append(blog.Watched(), configPath)
+	}, func(changed []string) {
+		start := time.Now()
+		var err error
+		if stale || slices.Contains(changed, filepath.Clean(configPath)) {
+			var rebuilt *site.Site
+			if rebuilt, err = build(configPath, profile, dev, false); err == nil {
+				blog = rebuilt
+			}
+		} else {
+			err = blog.Rebuild(changed)
+		}
+		if err == nil {
+			err = blog.Write()
+		}
+		if stale = err != nil; stale {
+			slog.Error("rebuild failed", "err", err)
+			return
+		}
+		slog.Info("rebuilt", "changed", strings.Join(changed, ", "), "duration", time.Since(start).Round(time.Millisecond).String())
+	})
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"be/site"
+)
+
+var checkLinksCommand = command{
+	Help: "build the site, and report the links of the entries that point nowhere",
+	Setup: func(fs *flag.FlagSet) func([]string) error {
+		configPath := configFlag(fs)
+		profile := profileFlag(fs, "")
+		opts := site.LinkCheck{}
+		fs.BoolVar(&opts.External, "external", false, "also request the links to other sites")
+		fs.IntVar(&opts.Concurrency, "concurrency", 8, "number of external requests in flight at most")
+		fs.DurationVar(&opts.Timeout, "timeout", 10*time.Second, "time to wait for an external link to respond")
+		fs.DurationVar(&opts.MaxAge, "max-age", 24*time.Hour, "do not request external links found working within this time again")
+		return func(args []string) error {
+			if len(args) > 0 {
+				return fmt.Errorf("unexpected arguments: %v", args)
+			}
+			cfg, err := site.LoadConfig(*configPath, *profile)
+			if err != nil {
+				return err
+			}
+			blog := site.New(cfg)
+			if err := blog.Build(); err != nil {
+				return err
+			}
+			broken := blog.CheckLinks(opts)
+			for _, err := range broken {
+				slog.Warn(err.Error())
+			}
+			if len(broken) > 0 {
+				return fmt.Errorf("%d broken link(s) found", len(broken))
+			}
+			return nil
+		}
+	},
+}
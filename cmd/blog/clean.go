@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"be/site"
+)
+
+// The output directory published by serve -webhook is a symlink into
+// <output>.releases (see site.Publisher): -orphans cleans the release it
+// points to, and leaves the symlink alone, otherwise the symlink and all
+// releases are removed.
+var cleanCommand = command{
+	Help: "remove the output directory, or only the files no longer built",
+	Setup: func(fs *flag.FlagSet) func([]string) error {
+		configPath := configFlag(fs)
+		profile := profileFlag(fs, "")
+		cache := fs.Bool("cache", false, "also remove the build cache (scaled images, ...)")
+		orphans := fs.Bool("orphans", false, "build the site, and only remove the files of the output directory it no longer produces (e.g. of renamed entries)")
+		dryRun := fs.Bool("dry-run", false, "with -orphans, only list the files instead of removing them")
+		return func(args []string) error {
+			if len(args) > 0 {
+				return fmt.Errorf("unexpected arguments: %v", args)
+			}
+			if *dryRun && !*orphans {
+				return fmt.Errorf("-dry-run requires -orphans")
+			}
+			cfg, err := site.LoadConfig(*configPath, *profile)
+			if err != nil {
+				return err
+			}
+			if *orphans {
+				return cleanOrphans(cfg, *dryRun)
+			}
+			// published by serve -webhook, the output is a symlink to the
+			// current of the releases
+			if err := os.RemoveAll(filepath.Clean(cfg.Output) + ".releases"); err != nil {
+				return err
+			}
+			if err := os.RemoveAll(cfg.Output); err != nil {
+				return err
+			}
+			if *cache {
+				return os.RemoveAll(cfg.Cache)
+			}
+			return nil
+		}
+	},
+}
+
+func cleanOrphans(cfg site.Config, dryRun bool) error {
+	blog := site.New(cfg)
+	if err := blog.Build(); err != nil {
+		return err
+	}
+	var orphans []string
+	var err error
+	if dryRun {
+		orphans, err = blog.Orphans()
+	} else {
+		orphans, err = blog.RemoveOrphans()
+	}
+	for _, p := range orphans {
+		fmt.Println(p)
+	}
+	return err
+}
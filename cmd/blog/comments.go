@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"be/site"
+)
+
+var commentsCommand = command{
+	Args: "import <submission>... | pending | approve <comment>...",
+	Help: "moderate comments: import submissions of the comment form (- reads one from stdin), list the comments waiting for approval, approve them (reject one by removing its file)",
+	Setup: func(fs *flag.FlagSet) func([]string) error {
+		configPath := configFlag(fs)
+		profile := profileFlag(fs, "")
+		return func(args []string) error {
+			if len(args) == 0 {
+				return fmt.Errorf("import, pending or approve?")
+			}
+			cfg, err := site.LoadConfig(*configPath, *profile)
+			if err != nil {
+				return err
+			}
+			blog := site.New(cfg)
+			switch action, args := args[0], args[1:]; action {
+			case "import":
+				if len(args) == 0 {
+					return fmt.Errorf("no submissions to import")
+				}
+				if err := blog.Load(); err != nil {
+					return err
+				}
+				var errs []error
+				for _, name := range args {
+					p, err := importComment(blog, name)
+					if err != nil {
+						errs = append(errs, fmt.Errorf("%s: %w", name, err))
+						continue
+					}
+					fmt.Println(p)
+				}
+				return errors.Join(errs...)
+			case "pending":
+				if len(args) > 0 {
+					return fmt.Errorf("unexpected arguments: %v", args)
+				}
+				pending, err := blog.PendingComments()
+				if err != nil {
+					return err
+				}
+				for _, p := range pending {
+					fmt.Println(p)
+				}
+				return nil
+			case "approve":
+				var errs []error
+				for _, p := range args {
+					errs = append(errs, site.ApproveComment(p))
+				}
+				return errors.Join(errs...)
+			default:
+				return fmt.Errorf("unknown action: %s", action)
+			}
+		}
+	},
+}
+
+func importComment(blog *site.Site, name string) (string, error) {
+	if name == "-" {
+		bs, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", err
+		}
+		return blog.ImportComment(bs, time.Now())
+	}
+	bs, err := os.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+	received := time.Now()
+	if fi, err := os.Stat(name); err == nil {
+		received = fi.ModTime()
+	}
+	return blog.ImportComment(bs, received)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"be/site"
+)
+
+var deployCommand = command{
+	Args: "[target]",
+	Help: "build the site and upload what changed since the last deploy to a target of the configuration",
+	Setup: func(fs *flag.FlagSet) func([]string) error {
+		configPath := configFlag(fs)
+		profile := profileFlag(fs, "")
+		skipBuild := fs.Bool("skip-build", false, "deploy the output directory as it is")
+		dryRun := fs.Bool("dry-run", false, "only list the files that would be uploaded and deleted, and the entries that would be announced, without writing the output directory or running the hooks")
+		announce := fs.Bool("announce", true, "announce newly published entries on mastodon and bluesky afterwards, if configured")
+		return func(args []string) error {
+			if len(args) > 1 {
+				return fmt.Errorf("unexpected arguments: %v", args[1:])
+			}
+			cfg, err := site.LoadConfig(*configPath, *profile)
+			if err != nil {
+				return err
+			}
+			target, err := deployTarget(cfg, args)
+			if err != nil {
+				return err
+			}
+			blog := site.New(cfg)
+			if !*skipBuild && !*dryRun {
+				if err := blog.RunHooks(site.HookPreBuild, target); err != nil {
+					return err
+				}
+			}
+			if !*skipBuild {
+				if err := blog.Build(); err != nil {
+					return err
+				}
+			}
+			// planned from the outputs of the build, a dry run writes none
+			if !*skipBuild && !*dryRun {
+				if err := blog.Write(); err != nil {
+					return err
+				}
+			}
+			if !*skipBuild && !*dryRun {
+				if err := blog.RunHooks(site.HookPostBuild, target); err != nil {
+					return err
+				}
+			}
+			d, err := blog.PlanDeploy(target)
+			if err != nil {
+				return err
+			}
+			*announce = *announce && (cfg.Mastodon.Server != "" || cfg.Bluesky.Server != "")
+			var announcements []site.Announcement
+			if *announce {
+				if *skipBuild {
+					if err := blog.Load(); err != nil {
+						return err
+					}
+				}
+				if announcements, err = blog.PlanAnnouncements(); err != nil {
+					return err
+				}
+			}
+			if *dryRun {
+				for _, p := range d.Upload {
+					fmt.Printf("upload %s\n", p)
+				}
+				for _, p := range d.Delete {
+					fmt.Printf("delete %s\n", p)
+				}
+				for _, a := range announcements {
+					fmt.Printf("announce %s on %s\n", a.Entry.Slug, a.Service)
+				}
+				return nil
+			}
+			if err := blog.RunHooks(site.HookPreDeploy, target); err != nil {
+				return err
+			}
+			start := time.Now()
+			if err := blog.Deploy(d); err != nil {
+				return err
+			}
+			slog.Info("deployed", "target", target, "uploaded", len(d.Upload), "deleted", len(d.Delete), "duration", time.Since(start).Round(time.Millisecond).String())
+			if *announce {
+				if err := blog.Announce(announcements); err != nil {
+					return err
+				}
+			}
+			if *announce && len(announcements) > 0 && !*skipBuild {
+				// for the announced entries to link the posts
+				if err := blog.Build(); err != nil {
+					return err
+				}
+				if err := blog.Write(); err != nil {
+					return err
+				}
+				if d, err = blog.PlanDeploy(target); err != nil {
+					return err
+				}
+				if err := blog.Deploy(d); err != nil {
+					return err
+				}
+			}
+			return blog.RunHooks(site.HookPostDeploy, target)
+		}
+	},
+}
+
+// deployTarget is the one named in args, or else the only one configured.
+func deployTarget(cfg site.Config, args []string) (string, error) {
+	if len(args) == 1 {
+		return args[0], nil
+	}
+	names := make([]string, 0, len(cfg.Deploy))
+	for name := range cfg.Deploy {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	switch len(names) {
+	case 0:
+		return "", fmt.Errorf("no deploy targets configured")
+	case 1:
+		return names[0], nil
+	default:
+		return "", fmt.Errorf("which target? %s", strings.Join(names, ", "))
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+
+	"be/markup"
+)
+
+type command struct {
+	// Arguments following the flags, for the usage line.
+	Args string
+	Help string
+	// Setup registers the flags of the command, and returns the function
+	// running it with the remaining arguments.
+	Setup func(fs *flag.FlagSet) func(args []string) error
+}
+
+var commands = map[string]command{
+	"build": buildCommand,
+	"serve": serveCommand,
+	"new": newCommand,
+	"clean": cleanCommand,
+	"lint": lintCommand,
+	"spell": spellCommand,
+	"check-links": checkLinksCommand,
+	"check-a11y": checkA11yCommand,
+	"deploy": deployCommand,
+	"comments": commentsCommand,
+	"newsletter": newsletterCommand,
+	"stats": statsCommand,
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: blog <command> [flags] [args]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "commands:")
+	names := make([]string, 0, len(commands))
+	width := 0
+	for name := range commands {
+		names = append(names, name)
+		width = max(width, len(name))
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-*s %s\n", width, name, commands[name].Help)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "run `blog <command> -h` for the flags of a command")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	name := os.Args[1]
+	if name == "help" || name == "-h" || name == "-help" || name == "--help" {
+		usage()
+		return
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n\n", name)
+		usage()
+		os.Exit(2)
+	}
+
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: blog %s [flags] %s\n\n%s\n\nflags:\n", name, cmd.Args, cmd.Help)
+		fs.PrintDefaults()
+	}
+	setupLog := logFlags(fs)
+	run := cmd.Setup(fs)
+	fs.Parse(os.Args[2:])
+	if err := setupLog(); err != nil {
+		fmt.Fprintf(os.Stderr, "blog %s: %s\n", name, err)
+		os.Exit(2)
+	}
+	if err := run(fs.Args()); err != nil {
+		// one line per error, so that each can be grepped for
+		for _, err := range flatten(err) {
+			slog.Error(err.Error(), errorAttrs(name, err)...)
+		}
+		os.Exit(1)
+	}
+}
+
+// logFlags registers the logging flags shared by all commands, and returns
+// the function setting up the default logger accordingly.
+func logFlags(fs *flag.FlagSet) func() error {
+	verbose := fs.Bool("v", false, "verbose, also log debug messages")
+	fs.BoolVar(verbose, "verbose", false, "same as -v")
+	quiet := fs.Bool("q", false, "quiet, only log errors")
+	fs.BoolVar(quiet, "quiet", false, "same as -q")
+	format := fs.String("log-format", "text", "format of the log messages, text or json")
+	return func() error {
+		opts := &slog.HandlerOptions{Level: slog.LevelInfo}
+		switch {
+		case *verbose && *quiet:
+			return fmt.Errorf("-verbose and -quiet exclude each other")
+		case *verbose:
+			opts.Level = slog.LevelDebug
+		case *quiet:
+			opts.Level = slog.LevelError
+		}
+		switch *format {
+		case "text":
+			slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, opts)))
+		case "json":
+			slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, opts)))
+		default:
+			return fmt.Errorf("invalid log format: %s", *format)
+		}
+		return nil
+	}
+}
+
+// errorAttrs are logged with the error, errors in a source with their kind
+// and where they are (for editors to jump to, with -log-format json).
+func errorAttrs(command string, err error) []any {
+	attrs := []any{"command", command}
+	var e *markup.Error
+	if errors.As(err, &e) {
+		attrs = append(attrs, "kind", e.Kind.Error())
+		if e.Line > 0 {
+			attrs = append(attrs, "file", e.File, "line", e.Line, "column", e.Column)
+		}
+	}
+	return attrs
+}
+
+// flatten splits up errors joined by errors.Join.
+func flatten(err error) []error {
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return []error{err}
+	}
+	var errs []error
+	for _, err := range joined.Unwrap() {
+		errs = append(errs, flatten(err)...)
+	}
+	return errs
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+
+	"be/site"
+)
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// Built-in archetypes, templates of the source of a new entry, by name.
+// An archetype of the same name in the archetypes directory takes
+// precedence.
+// Forms may not be empty, hence the placeholders. New entries are drafts,
+// remove the (draft) to publish them.
+var archetypes = map[string]string{
+	"post": `(title {{.Title}})
+(description @todo)
+(published {{.Date}})
+(draft)
+{{- if .Tags }}
+(tags {{ join .Tags " " }})
+{{- end }}
+
+(body
+
+@todo
+
+)
+`,
+	"link": `(title {{.Title}})
+(description @todo)
+(published {{.Date}})
+(draft)
+{{- if .Tags }}
+(tags {{ join .Tags " " }})
+{{- end }}
+
+(body
+
+(link (url {{ or .URL "@todo" }}) (text {{.Title}}))
+
+@todo: why it is worth reading
+
+)
+`,
+	"photo": `(title {{.Title}})
+(description @todo)
+(published {{.Date}})
+(draft)
+{{- if .Tags }}
+(tags {{ join .Tags " " }})
+{{- end }}
+
+(body
+{{ if .Image }}
+(image
+	(path {{.Image}})
+	(alt @todo)
+	(kind hero)
+	(text @todo: caption))
+{{ end }}
+@todo
+
+)
+`,
+}
+
+// archetypeData is what the archetype templates are executed with.
+type archetypeData struct {
+	Title, Slug string
+	// Publication date, yyyy-mm-dd
+	Date string
+	Tags []string
+	// Linked url (link archetype) and image path (photo archetype), if
+	// given.
+	URL, Image string
+}
+
+var newCommand = command{
+	Args: "[slug]",
+	Help: "create the source of a new entry from an archetype (post, link, photo, ...)",
+	Setup: func(flags *flag.FlagSet) func([]string) error {
+		configPath := configFlag(flags)
+		profile := profileFlag(flags, "")
+		title := flags.String("title", "", "title of the entry (default: the slug)")
+		archetype := flags.String("archetype", "post", "template of the source, see the archetypes directory for more than post, link and photo")
+		tags := flags.String("tags", "", "comma separated tags of the entry")
+		url := flags.String("url", "", "url the entry links to (link archetype)")
+		img := flags.String("image", "", "path of the photo (photo archetype)")
+		return func(args []string) error {
+			if len(args) > 1 || (len(args) == 0 && *title == "") {
+				return errors.New("expected the slug of the entry, or a -title to derive it from")
+			}
+			slug := slugify(*title)
+			if len(args) == 1 {
+				slug = args[0]
+			}
+			if !slugPattern.MatchString(slug) {
+				return fmt.Errorf("invalid slug (use lowercase letters, digits and dashes): %s", slug)
+			}
+			cfg, err := site.LoadConfig(*configPath, *profile)
+			if err != nil {
+				return err
+			}
+			if *title == "" {
+				*title = slug
+			}
+			tmpl, err := loadArchetype(cfg.Archetypes, *archetype)
+			if err != nil {
+				return err
+			}
+			data := archetypeData{
+				Title: *title,
+				Slug: slug,
+				Date: time.Now().Format(time.DateOnly),
+				Tags: strings.FieldsFunc(*tags, func(r rune) bool {
+					return r == ',' || unicode.IsSpace(r)
+				}),
+				URL: *url,
+				Image: *img,
+			}
+			src := &bytes.Buffer{}
+			if err := tmpl.Execute(src, data); err != nil {
+				return err
+			}
+
+			p := filepath.Join(cfg.Sources, slug+".be")
+			if _, err := os.Stat(p); !errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("%s already exists", p)
+			}
+			if err := os.MkdirAll(cfg.Sources, 0755); err != nil {
+				return err
+			}
+			if err := os.WriteFile(p, src.Bytes(), 0644); err != nil {
+				return err
+			}
+			fmt.Println(p)
+			return nil
+		}
+	},
+}
+
+// loadArchetype reads the archetype from dir/<name>.be, falling back to the
+// built-in one.
+func loadArchetype(dir, name string) (*template.Template, error) {
+	text, ok := archetypes[name]
+	if dir != "" {
+		bs, err := os.ReadFile(filepath.Join(dir, name+".be"))
+		if err == nil {
+			text, ok = string(bs), true
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	if !ok {
+		names := make([]string, 0, len(archetypes))
+		for name := range archetypes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown archetype: %s (built-in: %s)", name, strings.Join(names, ", "))
+	}
+	return template.New(name).Funcs(template.FuncMap{"join": strings.Join}).Parse(text)
+}
+
+// slugify lowercases the title, and replaces everything but letters and
+// digits with dashes. Accents are dropped (Über => uber).
+func slugify(title string) string {
+	sb := &strings.Builder{}
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		if base, ok := unaccented[r]; ok {
+			r = base
+		}
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+			dash = true
+			continue
+		}
+		if dash && sb.Len() > 0 {
+			sb.WriteByte('-')
+		}
+		dash = false
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// Accented letters and the letter they are based on.
+var unaccented = map[rune]rune{
+	'à': 'a', 'á': 'a', 'â': 'a', 'ä': 'a', 'ã': 'a', 'å': 'a',
+	'ç': 'c',
+	'è': 'e', 'é': 'e', 'ê': 'e', 'ë': 'e',
+	'ì': 'i', 'í': 'i', 'î': 'i', 'ï': 'i',
+	'ñ': 'n',
+	'ò': 'o', 'ó': 'o', 'ô': 'o', 'ö': 'o', 'õ': 'o', 'ø': 'o',
+	'ù': 'u', 'ú': 'u', 'û': 'u', 'ü': 'u',
+	'ý': 'y', 'ÿ': 'y',
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"be/site"
+)
+
+var newsletterCommand = command{
+	Help: "collect the entries published since the last issue into a digest, and write it to a file (.eml or .html) or push it as a draft to the newsletter provider",
+	Setup: func(fs *flag.FlagSet) func([]string) error {
+		configPath := configFlag(fs)
+		profile := profileFlag(fs, "")
+		output := fs.String("o", "", "write the issue to this file, an .eml (to open as draft in a mail client) or .html")
+		push := fs.Bool("push", false, "create the issue as a draft at the provider of the configuration")
+		lang := fs.String("lang", "", "only include entries in this language")
+		since := fs.String("since", "", "only include entries published after this date (2006-01-02), e.g. for the first issue")
+		dryRun := fs.Bool("dry-run", false, "do not remember the entries as sent, the next issue includes them again")
+		return func(args []string) error {
+			if len(args) > 0 {
+				return fmt.Errorf("unexpected arguments: %v", args)
+			}
+			if *output == "" && !*push {
+				return fmt.Errorf("-o or -push?")
+			}
+			var after time.Time
+			if *since != "" {
+				var err error
+				if after, err = time.Parse(time.DateOnly, *since); err != nil {
+					return fmt.Errorf("-since: %w", err)
+				}
+			}
+			cfg, err := site.LoadConfig(*configPath, *profile)
+			if err != nil {
+				return err
+			}
+			blog := site.New(cfg)
+			if err := blog.Load(); err != nil {
+				return err
+			}
+			issue, err := blog.NextIssue(*lang, after)
+			if err != nil {
+				return err
+			}
+			if len(issue.Entries) == 0 {
+				return fmt.Errorf("nothing new since the last issue")
+			}
+			if *output != "" {
+				data := issue.HTML
+				switch filepath.Ext(*output) {
+				case ".html":
+				case ".eml":
+					if data, err = blog.EML(issue); err != nil {
+						return err
+					}
+				default:
+					return fmt.Errorf("-o: .eml or .html?")
+				}
+				if err := os.WriteFile(*output, data, 0644); err != nil {
+					return err
+				}
+			}
+			if *push {
+				draft, err := blog.PushIssue(issue)
+				if err != nil {
+					return err
+				}
+				slog.Info("pushed issue", "issue", issue.Number, "draft", draft)
+			}
+			slog.Info("newsletter", "issue", issue.Number, "subject", issue.Subject, "entries", len(issue.Entries))
+			if *dryRun {
+				return nil
+			}
+			return blog.RecordIssue(issue)
+		}
+	},
+}
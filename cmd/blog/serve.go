@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"be/site"
+)
+
+var serveCommand = command{
+	Help: "serve the site locally, rebuilding it and reloading the browser on changes",
+	Setup: func(fs *flag.FlagSet) func([]string) error {
+		configPath := configFlag(fs)
+		profile := profileFlag(fs, "dev")
+		addr := fs.String("addr", "localhost:8080", "address to listen on")
+		webhook := fs.Bool("webhook", false, "serve the output directory in production instead, and pull, rebuild and publish it on every authenticated POST to /_webhook (the secret is read from $BLOG_WEBHOOK_SECRET)")
+		preview := fs.Bool("preview", false, "serve a preview including drafts and future entries, only to those who know the token in $BLOG_PREVIEW_TOKEN (as ?token= or basic auth password)")
+		return func(args []string) error {
+			if len(args) > 0 {
+				return fmt.Errorf("unexpected arguments: %v", args)
+			}
+			if *webhook && *preview {
+				return fmt.Errorf("-webhook and -preview exclude each other")
+			}
+			if (*webhook || *preview) && !isSet(fs, "profile") {
+				*profile = "" // the one of the config, not dev
+			}
+			switch {
+			case *webhook:
+				return site.NewPublisher(*configPath, *profile, os.Getenv("BLOG_WEBHOOK_SECRET")).ListenAndServe(*addr)
+			case *preview:
+				token := os.Getenv("BLOG_PREVIEW_TOKEN")
+				if token == "" {
+					return fmt.Errorf("set the token of the preview in $BLOG_PREVIEW_TOKEN")
+				}
+				return site.NewPreviewServer(*configPath, *profile, token).ListenAndServe(*addr)
+			default:
+				return site.NewServer(*configPath, *profile).ListenAndServe(*addr)
+			}
+		}
+	},
+}
+
+// isSet reports whether the flag was given on the command line.
+func isSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+	return set
+}
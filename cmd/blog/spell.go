@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"sort"
+
+	"be/site"
+)
+
+var spellCommand = command{
+	Help: "check the spelling of the entries",
+	Setup: func(fs *flag.FlagSet) func([]string) error {
+		configPath := configFlag(fs)
+		profile := profileFlag(fs, "")
+		words := fs.Bool("words", false, "only list the misspelled words, one per line (e.g. to add them to the words of the site)")
+		return func(args []string) error {
+			if len(args) > 0 {
+				return fmt.Errorf("unexpected arguments: %v", args)
+			}
+			cfg, err := site.LoadConfig(*configPath, *profile)
+			if err != nil {
+				return err
+			}
+			blog := site.New(cfg)
+			if err := blog.Load(); err != nil {
+				return err
+			}
+			found, err := blog.Spellcheck()
+			if err != nil {
+				return err
+			}
+			if *words {
+				unique := map[string]bool{}
+				for _, m := range found {
+					unique[m.Word] = true
+				}
+				list := make([]string, 0, len(unique))
+				for w := range unique {
+					list = append(list, w)
+				}
+				sort.Strings(list)
+				for _, w := range list {
+					fmt.Println(w)
+				}
+				return nil
+			}
+			for _, m := range found {
+				slog.Warn(m.Error())
+			}
+			if len(found) > 0 {
+				return fmt.Errorf("%d misspelling(s) found", len(found))
+			}
+			return nil
+		}
+	},
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"be/component"
+	"be/site"
+)
+
+var statsCommand = command{
+	Args: "[access log]...",
+	Help: "count the page views and referrers in the access logs of the web server (nginx or caddy), and write them to a private stats page",
+	Setup: func(fs *flag.FlagSet) func([]string) error {
+		configPath := configFlag(fs)
+		profile := profileFlag(fs, "")
+		accessLog := fs.String("access-log", "", "access log to read, more can follow as arguments (rotated .gz ones as well)")
+		output := fs.String("o", "stats.html", "where to write the stats page, best not into the output directory")
+		top := fs.Int("top", 50, "number of pages and referrers listed")
+		return func(args []string) error {
+			if *accessLog != "" {
+				args = append([]string{*accessLog}, args...)
+			}
+			if len(args) == 0 {
+				return fmt.Errorf("no access logs given")
+			}
+			cfg, err := site.LoadConfig(*configPath, *profile)
+			if err != nil {
+				return err
+			}
+			st := site.New(cfg).NewAccessStats()
+			var errs []error
+			for _, name := range args {
+				if err := st.ReadFile(name); err != nil {
+					errs = append(errs, err)
+				}
+			}
+			if err := errors.Join(errs...); err != nil {
+				return err
+			}
+			stats := st.Stats(*top)
+			stats.BlogName = cfg.BlogName
+			buf := &bytes.Buffer{}
+			if err := component.RenderStats(buf, stats); err != nil {
+				return err
+			}
+			if err := os.WriteFile(*output, buf.Bytes(), 0644); err != nil {
+				return err
+			}
+			slog.Info("stats", "views", stats.Views, "visitors", stats.Visitors, "bots", stats.Bots, "page", *output)
+			return nil
+		}
+	},
+}
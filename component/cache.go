@@ -0,0 +1,65 @@
+package component
+
+import (
+	"encoding/gob"
+	"html/template"
+	"strings"
+	"sync/atomic"
+)
+
+// The content elements Eval produces, for entries to be encoded with
+// encoding/gob (e.g. to cache them).
+func init() {
+	gob.Register(Text(""))
+	gob.Register(&Paragraph{})
+	gob.Register(&Link{})
+	gob.Register(&Image{})
+	gob.Register(&CodeBlock{})
+	gob.Register(Partial{})
+}
+
+// CacheFunc returns the result of build, which is fully determined by the
+// inputs, from a cache if it was computed before.
+type CacheFunc func(kind string, build func() ([]byte, error), inputs ...[]byte) ([]byte, error)
+
+var cache atomic.Pointer[CacheFunc]
+
+func init() {
+	SetCache(func(kind string, build func() ([]byte, error), inputs ...[]byte) ([]byte, error) {
+		return build()
+	})
+}
+
+// SetCache keeps the results of the expensive parts of rendering (the
+// highlighting of code blocks) in the cache.
+func SetCache(c CacheFunc) {
+	cache.Store(&c)
+}
+
+// cachedLines caches the lines of highlight, which are fully determined by
+// the inputs.
+func cachedLines(kind string, highlight func() ([]template.HTML, error), inputs ...string) ([]template.HTML, error) {
+	var bs [][]byte
+	for _, in := range inputs {
+		bs = append(bs, []byte(in))
+	}
+	joined, err := (*cache.Load())(kind, func() ([]byte, error) {
+		lines, err := highlight()
+		if err != nil {
+			return nil, err
+		}
+		ss := make([]string, len(lines))
+		for i, l := range lines {
+			ss[i] = string(l)
+		}
+		return []byte(strings.Join(ss, "\n")), nil
+	}, bs...)
+	if err != nil || len(joined) == 0 {
+		return nil, err
+	}
+	var lines []template.HTML
+	for _, l := range strings.Split(string(joined), "\n") {
+		lines = append(lines, template.HTML(l))
+	}
+	return lines, nil
+}
@@ -1,19 +1,83 @@
 package component
 
-type CodeLine string
+import (
+	"bytes"
+	"html"
+	"html/template"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/lexers"
+)
 
 type CodeBlock struct {
-	Lines []CodeLine
+	// Name of the file the code is from, also used to detect the language.
+	File string
+	// Chroma lexer name, e.g. go, lisp, bash
+	Language string
+	Source string
+}
+
+var _ ContentElement = (*CodeBlock)(nil)
+
+func (c CodeBlock) Render() (template.HTML, error) {
+	buf := &bytes.Buffer{}
+	err := pages().Render(buf, "CodeBlock", c)
+	return template.HTML(buf.String()), err
+}
+
+func (c CodeBlock) lexer() chroma.Lexer {
+	var l chroma.Lexer
+	if c.Language != "" {
+		l = lexers.Get(c.Language)
+	}
+	if l == nil && c.File != "" {
+		l = lexers.Match(c.File)
+	}
+	if l == nil {
+		l = lexers.Analyse(c.Source)
+	}
+	if l == nil {
+		l = lexers.Fallback
+	}
+	return chroma.Coalesce(l)
 }
 
-const HtmlCodeBlock = `
-{{ define "CodeBlock" }}
-<pre><code>
-{{ range .Lines }}
-<span class="line-number">{{ .Line }}</span>
-{{ end }}
-</pre></code>
-{{ end }}
-`
-
-//`<span class="comment">{{ .Comment }}</span>`
+// Lines returns the highlighted source, line by line. Tokens are wrapped in
+// spans with the short class names of chroma (k for keywords, c for comments,
+// ...), the colors are set by the syntax theme of the site.
+func (c CodeBlock) Lines() ([]template.HTML, error) {
+	return cachedLines("highlight", c.highlight, c.Language, c.File, c.Source)
+}
+
+func (c CodeBlock) highlight() ([]template.HTML, error) {
+	src := strings.Trim(c.Source, "\n")
+	it, err := c.lexer().Tokenise(nil, src)
+	if err != nil {
+		return nil, err
+	}
+	var lines []template.HTML
+	for _, line := range chroma.SplitTokensIntoLines(it.Tokens()) {
+		sb := &strings.Builder{}
+		for _, t := range line {
+			text := html.EscapeString(strings.TrimSuffix(t.Value, "\n"))
+			if class := tokenClass(t.Type); class != "" && text != "" {
+				sb.WriteString(`<span class="` + class + `">` + text + `</span>`)
+			} else {
+				sb.WriteString(text)
+			}
+		}
+		lines = append(lines, template.HTML(sb.String()))
+	}
+	return lines, nil
+}
+
+// tokenClass returns the class of the most specific token type that has one.
+func tokenClass(t chroma.TokenType) string {
+	for _, t := range []chroma.TokenType{t, t.SubCategory(), t.Category()} {
+		if class, ok := chroma.StandardTypes[t]; ok {
+			return class
+		}
+	}
+	return ""
+}
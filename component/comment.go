@@ -0,0 +1,22 @@
+package component
+
+import "time"
+
+// Comment left on an entry, and approved to be shown under it.
+type Comment struct {
+	Author string
+	// Website of the author, optional.
+	URL string
+	Published time.Time
+	Paragraphs []string
+}
+
+// CommentForm lets readers comment on an entry.
+type CommentForm struct {
+	// Url the form posts to.
+	Action string
+	// Slug of the entry commented on.
+	Entry string
+	// The action is a mailto: address, the form is sent as text/plain.
+	Mail bool
+}
@@ -2,6 +2,8 @@ package component
 
 import (
 	"fmt"
+	"html/template"
+	"strings"
 	"time"
 
 	//"be/lex"
@@ -13,6 +15,19 @@ func (t Tag) String() string {
 	return ":" + string(t)
 }
 
+func (t Tag) Name() string {
+	return string(t)
+}
+
+func (t Tag) Slug() string {
+	return strings.ToLower(string(t))
+}
+
+// Link to the index of all entries with this tag.
+func (t Tag) Link() string {
+	return "/tags/" + t.Slug() + "/"
+}
+
 type Tags []Tag
 
 func (ts Tags) KeywordList() (s string) {
@@ -45,6 +60,37 @@ type Meta struct {
 	Revisions []time.Time
 	Topic string
 	EstReadingTime ReadingTime
+	// Ask search engines not to index the page.
+	NoIndex bool
+	// Absolute url of the image shown when the entry is shared.
+	Image string
+	// Absolute url of the feed the page belongs to.
+	FeedURL string
+	Archived bool
+	// Not meant to be published yet, only included in builds that ask for
+	// drafts.
+	Draft bool
+	Expires time.Time
+	// Archived or expired at the time of the build.
+	Outdated bool
+	// Exif groups (camera, exposure, date) kept in published photos.
+	KeepExif []string
+	// Stylesheets and scripts the page loads.
+	Stylesheets, Scripts []Asset
+	Icons []Icon
+	// Site relative url of the web app manifest.
+	Manifest string
+	ThemeColor string
+	// Show the checkbox switching between the light and dark color scheme.
+	ThemeToggle bool
+	// Snippet (script, tracking pixel, ...) included in the head of the page.
+	Analytics template.HTML
+	// Url webmentions to the page are sent to.
+	Webmention string
+	// Profiles of the author on other sites, linked as rel=me.
+	Identities []string
+	// Endpoints of the IndieAuth server the site as identity logs in with.
+	AuthorizationEndpoint, TokenEndpoint, IndieAuthMetadata string
 }
 
 func (m Meta) IsRevised() bool {
@@ -65,6 +111,15 @@ func (m Meta) CopyYear() int {
 	return m.Published.Year()
 }
 
+func (b EntryData) FirstImage() *Image {
+	for _, c := range b.Content {
+		if img, ok := c.(*Image); ok {
+			return img
+		}
+	}
+	return nil
+}
+
 type Author struct {
 	Name string
 	EMail string
@@ -73,6 +128,33 @@ type Author struct {
 type Language struct {
 	Link string
 	Language string
+	// ISO 639 code, e.g. "en"
+	HrefLang string
+}
+
+var languageNames = map[string]string{
+	"en": "English",
+	"de": "Deutsch",
+	"fr": "Français",
+	"it": "Italiano",
+	"nl": "Nederlands",
+	"es": "Español",
+}
+
+// LanguageName returns the name of the language (in that language), or the
+// code itself if unknown.
+func LanguageName(code string) string {
+	if name, ok := languageNames[code]; ok {
+		return name
+	}
+	return code
+}
+
+// Syndication is a copy of an entry on another site.
+type Syndication struct {
+	// e.g. the host of the url
+	Name string
+	URL string
 }
 
 type EntryData struct {
@@ -80,119 +162,25 @@ type EntryData struct {
 	Title, AltTitle string
 	Author Author
 	Tags Tags
+	// Old paths of this entry that should redirect to it.
+	Aliases []string
+	// Slug of the entry this one is a translation of.
+	TranslationOf string
+	// Template the page is rendered with, see RenderEntry.
+	Layout string
+	// Urls of copies of this entry posted on other sites.
+	Syndication []string
 	Meta Meta
 	Abstract string
 	Languages []Language
 	Content []ContentElement
+	// Other entries linking to this one.
+	Backlinks []PostItem
+	// Copies of this entry on other sites, linked as "also posted on".
+	Copies []Syndication
+	// Reactions from other sites.
+	Mentions Mentions
+	Comments []Comment
+	// Nil if comments are not accepted.
+	CommentForm *CommentForm
 }
-
-const HtmlEntry = `
-{{ define "Entry" }}
-<!DOCTYPE html>
-<html lang="{{.Meta.Language}}">
-	<head>
-		<meta charset="utf-8" />
-		<meta name="viewport" content="width=device-width, initial-scale=1.0" />
-		<link rel="stylesheet" href="/public/styles.css" />
-		<link rel="icon" type="image/png" href="/public/favicon.png" />
-		<link rel="canonical" href="{{.Meta.CanonicalURL}}" />
-		<title>{{.Title}} &mdash; ({{.BlogName}})</title>
-
-		<meta name="author" content="{{.Author.Name}}" />
-		<meta name="keywords" content="{{.Tags.KeywordList}}"/>
-		<meta name="description" content="{{.Meta.Description}}"/>
-		{{ if .Meta.IsRevised }}
-		<meta name="revised" content="{{.Meta.LastRevised}}" />
-		{{ end }}
-		<meta name="topic" content="{{.Meta.Topic}}">
-		<meta name="subject" content="{{.Meta.Topic}}">
-		<meta name="language" content="{{.Meta.Language}}">
-		<meta name="abstract" content="{{.Abstract}}">
-		<meta name="summary" content="{{.Abstract}}">
-		<meta name="url" content="{{.Meta.CanonicalURL}}">
-
-		<meta name="og:title" content="{{.Title}}"/>
-		<meta name="og:type" content="article"/>
-		<meta property="article:published_time" content="{{.Meta.Published}}" />
-		{{ if .Meta.IsRevised }}
-		<meta property="article:revised_time" content="{{.Meta.LastRevised}}" />
-		{{ end }}
-		<meta name="og:url" content="{{.Meta.CanonicalURL}}"/>
-		<meta name="og:site_name" content="{{.BlogName}}"/>
-		<meta name="og:description" content="{{.Meta.Description}}"/>
-	</head>
-	<body>
-		<div class="scroll-progress">
-			<div id="scroll-progress"></div>
-		</div>
-		<header>
-			<nav>
-				<p class="fill">
-				<!-- 2^7633587786 -->
-				<code>({{.BlogName}}</code>
-				<span class="keywords">
-					<code><a href="/index.html">:home</a></code>
-					<code><a href="/about.html">:about</a></code>
-					<code><a href="/rss.xml">:rss</a></code>
-				</span>
-				<code>)</code>
-				</p>
-			</nav>
-		</header>
-		<main>
-			<article>
-				<div class="title">
-					<h1>{{.Title}}</h1>
-					<aside class="content-info">
-						<div class="info">
-							<p class="published-date"><small>{{.Meta.Published}}</small></p>
-							<p class="time-est-reading"><small>{{.Meta.EstReadingTime}}</small></p>
-						</div>
-						<div class="taglist">
-							{{ range .Tags }}
-							<p><a href="/search?tags={{.}}">{{.}}</a></p>
-							{{ end}}
-						</div>
-					</aside>
-				</div>
-				<ul class="language-selection">
-					<li>English
-						<ul class="dropdown">
-							{{ range .Languages }}
-							<li><a href="{{.Link}}">{{.Language}}</a></li>
-							{{ end }}
-						</ul>
-					</li>
-				</ul>
-
-				{{ range .Content }}
-					{{ Render . }}
-				{{ end }}
-
-			</article>
-		</main>
-		<footer>
-			<p id="eof">STOP)))))</p>
-			<address>&copy; {{.Meta.CopyYear}} <a href="mailto:{{.Author.EMail}}?subject=RE: {{.Title}}">{{.Author.Name}}</a></address>
-			<span class="credits">
-				<a href="/about.html#credits">Font Licenses</a>
-				<a href="/about.html">About</a>
-				<a href="/rss.xml">RSS Feed</a>
-			</span>
-		</footer>
-		<script>
-			function calculateProgress() {
-				const winScroll = document.body.scrollTop || document.documentElement.scrollTop;
-				const height = document.documentElement.scrollHeight - document.documentElement.clientHeight;
-				const scrolled = (winScroll / height) * 100;
-				document.getElementById('scroll-progress').style.width = scrolled + "%";
-			}
-
-			window.onscroll = function() {
-				calculateProgress();
-			};
-		</script>
-	</body>
-</html>
-{{ end }}
-`
@@ -1,7 +1,16 @@
+// Package component evaluates the forms of an entry (see package lex) into
+// its data, and renders the pages of the site with the templates of the
+// theme.
+//
+// Eval and the render functions are safe for concurrent use. LoadTheme,
+// SetSite and SetCache change what all of them use, and are safe to call
+// while pages are rendered (a page rendered meanwhile may see both the old
+// and the new setting).
 package component
 
 import (
 	"bytes"
+	"cmp"
 	"errors"
 	"fmt"
 	"html/template"
@@ -9,26 +18,20 @@ import (
 	//"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"be/lex"
+	"be/tok"
 )
 
-var pages Template = Template{template.New("")}
-
-func init() {
-	pages.Funcs(template.FuncMap{
-		"Render": Render,
-	})
+// Kinds of errors of the evaluator, reported as *tok.Error.
+var (
+	ErrUnknownForm = errors.New("unknown form")
+	ErrMissingArgument = errors.New("missing argument")
+	ErrInvalidArgument = errors.New("invalid argument")
+	ErrInvalidDate = errors.New("invalid date")
+)
 
-	template.Must(pages.Parse(HtmlCodeBlock))
-	template.Must(pages.Parse(HtmlEntry))
-	template.Must(pages.Parse(HtmlSection))
-	template.Must(pages.Parse(HtmlSubsection))
-	template.Must(pages.Parse(HtmlParagraph))
-	template.Must(pages.Parse(HtmlLink))
-	template.Must(pages.Parse(HtmlAside))
-	template.Must(pages.Parse(HtmlSidenote))
-}
 
 type Template struct {
 	*template.Template
@@ -45,13 +48,31 @@ func String(root *lex.LLHead) string {
 	}
 
 	bs := &bytes.Buffer{}
-	err = pages.Render(bs, "Entry", data)
+	err = pages().Render(bs, "Entry", data)
 	if err != nil {
 		panic(err)
 	}
 	return bs.String()
 }
 
+func Eval(root *lex.LLHead) (*EntryData, error) {
+	return eval(nil, nil, root)
+}
+
+// RenderEntry renders the page with the layout of the entry, Entry if it
+// has none.
+func RenderEntry(w io.Writer, blog *EntryData) error {
+	return pages().Render(w, cmp.Or(blog.Layout, "Entry"), blog)
+}
+
+func RenderIndex(w io.Writer, index *IndexData) error {
+	return pages().Render(w, "Index", index)
+}
+
+func RenderRedirect(w io.Writer, target string) error {
+	return pages().Render(w, "Redirect", target)
+}
+
 func Handler(root *lex.LLHead) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		data, err := eval(nil, nil, root)
@@ -59,7 +80,7 @@ func Handler(root *lex.LLHead) http.HandlerFunc {
 			panic(err)
 		}
 
-		err = pages.Render(w, "Entry", data)
+		err = pages().Render(w, "Entry", data)
 		if err != nil {
 			panic(err)
 		}
@@ -77,6 +98,8 @@ type (
 		scopes []Scope
 	}
 	Args struct {
+		// offset of the function name (or bare text) in the source
+		pos int
 		next *lex.LLNode
 		finished bool
 		errs []error
@@ -89,21 +112,21 @@ func NewArgs(args *lex.LLNode) *Args {
 	}
 }
 
+// Pos is the offset (in runes) in the source of the form the arguments
+// belong to.
+func (a *Args) Pos() int {
+	return a.pos
+}
+
 func (a *Args) Next(name string) string {
 	if a.finished {
 		panic("invalid usage: all mandatory arguments must appear before optional ones")
 	}
 	if a.next == nil {
-		a.errs = append(a.errs, fmt.Errorf("missing argument: %s", name))
+		a.errs = append(a.errs, tok.Errorf(ErrMissingArgument, a.pos, "missing argument: %s", name))
 		return "<value missing>"
 	}
-	n := a.next.El
-	a.next = a.next.Next
-
-	if n.Type != lex.TypeText {
-		panic("arg must be of type text")
-	}
-	return string(n.Text)
+	return a.text(name)
 }
 
 func (a *Args) Optional(name string) string {
@@ -111,11 +134,16 @@ func (a *Args) Optional(name string) string {
 	if a.next == nil {
 		return ""
 	}
+	return a.text(name)
+}
+
+func (a *Args) text(name string) string {
 	n := a.next.El
 	a.next = a.next.Next
 
 	if n.Type != lex.TypeText {
-		panic("arg must be of type text")
+		a.errs = append(a.errs, tok.Errorf(ErrInvalidArgument, n.Pos, "%s must be text, not a form", name))
+		return "<value invalid>"
 	}
 	return string(n.Text)
 }
@@ -142,16 +170,12 @@ func (sc *Scopes) Resolve(name string) (fun BeFunc, err error) {
 			return fun, nil
 		}
 	}
-	return nil, fmt.Errorf("cannot resolve function: %s", name)
+	return nil, fmt.Errorf("%w: %s", ErrUnknownForm, name)
 }
 
 var beFuncs = Scope {
 	"root": func(blog *EntryData, scope Scope, args *Args) error {
-		// @todo: read defaults from config file?
-		blog.BlogName = "save-lisp-and-die"
-		blog.Author = Author{
-			Name: "cvl",
-		}
+		// defaults (blog name, author, ...) are filled in from the site config
 		return args.Finished()
 	},
 	"eof": func(blog *EntryData, scope Scope, args *Args) error {
@@ -175,6 +199,57 @@ var beFuncs = Scope {
 		}
 		return args.Finished()
 	},
+	"description": func(blog *EntryData, scope Scope, args *Args) error {
+		blog.Meta.Description = args.Next("description")
+		return args.Finished()
+	},
+	"summary": func(blog *EntryData, scope Scope, args *Args) error {
+		blog.Abstract = args.Next("summary")
+		return args.Finished()
+	},
+	"preview-image": func(blog *EntryData, scope Scope, args *Args) error {
+		blog.Meta.Image = args.Next("image path")
+		return args.Finished()
+	},
+	"published": func(blog *EntryData, scope Scope, args *Args) error {
+		date := args.Next("publishing date (yyyy-mm-dd)")
+		if err := args.Finished(); err != nil {
+			return err
+		}
+		t, err := parseDate(date, args.Pos())
+		blog.Meta.Published = t
+		return err
+	},
+	"revised": func(blog *EntryData, scope Scope, args *Args) error {
+		date := args.Next("revision date (yyyy-mm-dd)")
+		if err := args.Finished(); err != nil {
+			return err
+		}
+		t, err := parseDate(date, args.Pos())
+		blog.Meta.Revisions = append(blog.Meta.Revisions, t)
+		return err
+	},
+	"exif": func(blog *EntryData, scope Scope, args *Args) error {
+		blog.Meta.KeepExif = strings.Fields(args.Next("space separated list of exif groups to keep"))
+		return args.Finished()
+	},
+	"archived": func(blog *EntryData, scope Scope, args *Args) error {
+		blog.Meta.Archived = true
+		return args.Finished()
+	},
+	"draft": func(blog *EntryData, scope Scope, args *Args) error {
+		blog.Meta.Draft = true
+		return args.Finished()
+	},
+	"expires": func(blog *EntryData, scope Scope, args *Args) error {
+		date := args.Next("expiry date (yyyy-mm-dd)")
+		if err := args.Finished(); err != nil {
+			return err
+		}
+		t, err := parseDate(date, args.Pos())
+		blog.Meta.Expires = t
+		return err
+	},
 	"tags": func(blog *EntryData, scope Scope, args *Args) error {
 		tagStrs := strings.Split(args.Next("space separated tag list"), " ")
 		blog.Tags = make(Tags, len(tagStrs))
@@ -183,14 +258,111 @@ var beFuncs = Scope {
 		}
 		return args.Finished()
 	},
+	"language": func(blog *EntryData, scope Scope, args *Args) error {
+		blog.Meta.Language = strings.TrimSpace(args.Next("language code (ISO 639)"))
+		return args.Finished()
+	},
+	"translation-of": func(blog *EntryData, scope Scope, args *Args) error {
+		blog.TranslationOf = strings.TrimSpace(args.Next("slug of the original entry"))
+		return args.Finished()
+	},
+	"layout": func(blog *EntryData, scope Scope, args *Args) error {
+		blog.Layout = strings.TrimSpace(args.Next("name of the template"))
+		return args.Finished()
+	},
+	"aliases": func(blog *EntryData, scope Scope, args *Args) error {
+		blog.Aliases = append(blog.Aliases, strings.Fields(args.Next("space separated list of old paths"))...)
+		return args.Finished()
+	},
+	"syndication": func(blog *EntryData, scope Scope, args *Args) error {
+		blog.Syndication = append(blog.Syndication, strings.Fields(args.Next("space separated list of urls of copies"))...)
+		return args.Finished()
+	},
 	"body": func(blog *EntryData, scope Scope, args *Args) error {
-		for a := args.Next("content"); a != ""; a = args.Optional("additional content") {
-			blog.Content = append(blog.Content, Text(a))
+		text := func(blog *EntryData, scope Scope, args *Args) error {
+			appendText(blog, args.Next("text"))
+			return args.Finished()
+		}
+		scope["text"] = text
+		scope["t"] = text
+		scope["link"] = func(blog *EntryData, scope Scope, args *Args) error {
+			link := &Link{Pos: args.Pos()}
+			appendInline(blog, link)
+			scope["url"] = func(blog *EntryData, scope Scope, args *Args) error {
+				link.Link = strings.TrimSpace(args.Next("link url"))
+				link.External = strings.Contains(link.Link, "://")
+				return args.Finished()
+			}
+			scope["ref"] = func(blog *EntryData, scope Scope, args *Args) error {
+				link.Ref = strings.TrimSpace(args.Next("slug of the linked entry"))
+				return args.Finished()
+			}
+			scope["text"] = func(blog *EntryData, scope Scope, args *Args) error {
+				link.Text += args.Next("link text")
+				return args.Finished()
+			}
+			return args.Finished()
+		}
+		image := func(blog *EntryData, scope Scope, args *Args) error {
+			img := &Image{Pos: args.Pos()}
+			blog.Content = append(blog.Content, img)
+			scope["path"] = func(blog *EntryData, scope Scope, args *Args) error {
+				img.Path = args.Next("image path")
+				return args.Finished()
+			}
+			scope["alt"] = func(blog *EntryData, scope Scope, args *Args) error {
+				img.Alt = args.Next("alternative text")
+				return args.Finished()
+			}
+			scope["size"] = func(blog *EntryData, scope Scope, args *Args) error {
+				img.Size = strings.TrimSpace(args.Next("image size"))
+				return args.Finished()
+			}
+			scope["kind"] = func(blog *EntryData, scope Scope, args *Args) error {
+				img.Kind = strings.TrimSpace(args.Next("image kind"))
+				return args.Finished()
+			}
+			scope["text"] = func(blog *EntryData, scope Scope, args *Args) error {
+				img.Caption += args.Next("caption")
+				return args.Finished()
+			}
+			return args.Finished()
+		}
+		scope["image"] = image
+		scope["img"] = image
+		scope["partial"] = func(blog *EntryData, scope Scope, args *Args) error {
+			blog.Content = append(blog.Content, Partial{Name: strings.TrimSpace(args.Next("name of the partial"))})
+			return args.Finished()
+		}
+		scope["code"] = func(blog *EntryData, scope Scope, args *Args) error {
+			code := &CodeBlock{}
+			blog.Content = append(blog.Content, code)
+			scope["file"] = func(blog *EntryData, scope Scope, args *Args) error {
+				code.File = strings.TrimSpace(args.Next("file name"))
+				return args.Finished()
+			}
+			scope["lang"] = func(blog *EntryData, scope Scope, args *Args) error {
+				code.Language = strings.TrimSpace(args.Next("language"))
+				return args.Finished()
+			}
+			scope["text"] = func(blog *EntryData, scope Scope, args *Args) error {
+				code.Source += args.Next("code")
+				return args.Finished()
+			}
+			return args.Finished()
 		}
 		return args.Finished()
 	},
 }
 
+func parseDate(date string, pos int) (time.Time, error) {
+	t, err := time.Parse(time.DateOnly, strings.TrimSpace(date))
+	if err != nil {
+		return t, tok.Errorf(ErrInvalidDate, pos, "invalid date: %v", err)
+	}
+	return t, nil
+}
+
 /*
 func eval(blog *EntryData, scopes *Scopes, node *lex.LLNode) (*EntryData, error) {
 	el := nodes.El
@@ -217,9 +389,12 @@ func eval(blog *EntryData, scopes *Scopes, head *lex.LLHead) (nblog *EntryData,
 	if scopes == nil {
 		scopes = &Scopes{}
 		scopes.Push(beFuncs)
+		// functions define their nested ones in the top scope, which must
+		// never be the shared beFuncs
+		scopes.Push(Scope{})
 	}
 	var fun BeFunc
-	for c := head.First; c != nil; c = c.Next {
+	for c := head.First; c != nil; {
 		n := c.El
 		switch n.Type {
 		case lex.TypeForm:
@@ -229,17 +404,34 @@ func eval(blog *EntryData, scopes *Scopes, head *lex.LLHead) (nblog *EntryData,
 			if err != nil {
 				return blog, err
 			}
+			c = c.Next
 		case lex.TypeAtom:
 			fun, err = scopes.Resolve(string(n.Atom))
 			if err != nil {
-				return blog, err
+				return blog, tok.Errorf(ErrUnknownForm, n.Pos, "%v", err)
 			}
-			err = fun(blog, scopes.Top(), NewArgs(c.Next))
+			args := NewArgs(c.Next)
+			args.pos = n.Pos
+			err = fun(blog, scopes.Top(), args)
 			if err != nil {
 				return blog, err
 			}
+			c = args.next // continue after whatever the function consumed
 		case lex.TypeText:
-			//blog.Content = append(blog.Content, Text(n.Text))
+			// bare text is an implicit (text ...) form, if the scope
+			// defines one (e.g. inside of body), ignored otherwise
+			fun, err = scopes.Resolve("text")
+			if err != nil {
+				c = c.Next
+				continue
+			}
+			args := NewArgs(c)
+			args.pos = n.Pos
+			err = fun(blog, scopes.Top(), args)
+			if err != nil {
+				return blog, err
+			}
+			c = args.next
 		default:
 			panic(fmt.Errorf("unknown node type: %#v", n))
 		}
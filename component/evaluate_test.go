@@ -0,0 +1,77 @@
+package component
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"be/lex"
+	"be/tok"
+)
+
+const source = `(title Hello World)
+(published 2024-01-02)
+(body
+Some text (link (url /x) (text a link)).
+
+(code (lang go) (text \+func main() {}\+))
+)`
+
+func evalSource(t *testing.T, src string) *EntryData {
+	t.Helper()
+	tokens, err := tok.Tokenize([]rune(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := lex.Lex(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := Eval(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestRenderEntryConcurrent(t *testing.T) {
+	want := &bytes.Buffer{}
+	if err := RenderEntry(want, evalSource(t, source)); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got := &bytes.Buffer{}
+			if err := RenderEntry(got, evalSource(t, source)); err != nil {
+				t.Error(err)
+				return
+			}
+			if got.String() != want.String() {
+				t.Error("concurrent render differs")
+			}
+		}()
+	}
+	// the same theme and site, swapped in while rendering
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range 4 {
+			if err := LoadTheme(""); err != nil {
+				t.Error(err)
+			}
+			SetSite(&SiteData{})
+		}
+	}()
+	wg.Wait()
+}
+
+func TestEvalKeepsBuiltins(t *testing.T) {
+	n := len(beFuncs)
+	evalSource(t, source)
+	if len(beFuncs) != n {
+		t.Errorf("evaluation added to the built-in functions: %d, was %d", len(beFuncs), n)
+	}
+}
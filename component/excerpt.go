@@ -0,0 +1,49 @@
+package component
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+const excerptLength = 280
+
+// Excerpt is the summary given by the author, or else the beginning of the
+// first paragraph, cut at a sentence boundary.
+func (b EntryData) Excerpt() string {
+	if b.Abstract != "" {
+		return b.Abstract
+	}
+	for _, c := range b.Content {
+		if p, ok := c.(*Paragraph); ok {
+			return truncateSentences(plainText(p), excerptLength)
+		}
+	}
+	return ""
+}
+
+// truncateSentences returns as many full sentences of text as fit in max
+// characters. If not even the first sentence fits, it is cut at a word
+// boundary instead.
+func truncateSentences(text string, max int) string {
+	if utf8.RuneCountInString(text) <= max {
+		return text
+	}
+	end, n := 0, 0 // n: runes before i
+	for i, r := range text {
+		if n >= max {
+			break
+		}
+		n++
+		if (r == '.' || r == '!' || r == '?') && (i+1 == len(text) || text[i+1] == ' ') {
+			end = i + 1
+		}
+	}
+	if end > 0 {
+		return text[:end]
+	}
+	cut := []rune(text)[:max]
+	if i := strings.LastIndexByte(string(cut), ' '); i > 0 {
+		return string(cut)[:i] + "…"
+	}
+	return string(cut) + "…"
+}
@@ -0,0 +1,23 @@
+package component
+
+import "testing"
+
+func TestTruncateSentences(t *testing.T) {
+	for _, tt := range []struct {
+		text string
+		max int
+		want string
+	}{
+		{"Short.", 10, "Short."},
+		{"One. Two. Three.", 10, "One. Two."},
+		{"Ünï. Cödé. Thrée.", 10, "Ünï. Cödé."},
+		{"Is it? Yes! No.", 12, "Is it? Yes!"},
+		{"3.14 is pi. And more.", 14, "3.14 is pi."},
+		{"A sentence far too long to fit", 12, "A sentence…"},
+		{"Unbreakable", 4, "Unbr…"},
+	} {
+		if got := truncateSentences(tt.text, tt.max); got != tt.want {
+			t.Errorf("truncateSentences(%q, %d) = %q, want %q", tt.text, tt.max, got, tt.want)
+		}
+	}
+}
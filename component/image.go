@@ -0,0 +1,65 @@
+package component
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"strings"
+)
+
+type Image struct {
+	Path string
+	Alt string
+	Caption string
+	// One of the configured image sizes (thumb, medium, full, ...).
+	Size string
+	// Url of the largest available size of the image.
+	Full string
+	// inline, gallery, hero, ... determines which widths are generated.
+	// Hero images are loaded right away, all others lazily.
+	Kind string
+	// Intrinsic dimensions of the image at Path, reserve the space before it
+	// is loaded.
+	Width, Height int
+	SrcSet []ImageSource
+	Sizes string
+	// Alternative formats of SrcSet, as <picture> sources.
+	Sources []PictureSource
+	// Offset (in runes) of the (image) form in the source.
+	Pos int
+}
+
+type ImageSource struct {
+	Src string
+	Width int
+}
+
+type PictureSource struct {
+	// mime type, e.g. image/webp
+	Type string
+	SrcSet []ImageSource
+}
+
+func srcSetAttr(srcSet []ImageSource) string {
+	var srcs []string
+	for _, s := range srcSet {
+		srcs = append(srcs, fmt.Sprintf("%s %dw", s.Src, s.Width))
+	}
+	return strings.Join(srcs, ", ")
+}
+
+func (i Image) SrcSetAttr() string {
+	return srcSetAttr(i.SrcSet)
+}
+
+func (p PictureSource) SrcSetAttr() string {
+	return srcSetAttr(p.SrcSet)
+}
+
+var _ ContentElement = (*Image)(nil)
+
+func (i Image) Render() (template.HTML, error) {
+	buf := &bytes.Buffer{}
+	err := pages().Render(buf, "Image", i)
+	return template.HTML(buf.String()), err
+}
@@ -0,0 +1,11 @@
+package component
+
+type IndexData struct {
+	BlogName string
+	Title string
+	Description string
+	Author Author
+	Meta Meta
+	Languages []Language
+	Lists []PostList
+}
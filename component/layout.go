@@ -0,0 +1,13 @@
+package component
+
+type Asset struct {
+	// Site relative url.
+	Href string
+	// Subresource integrity hash, e.g. sha384-...
+	Integrity string
+}
+
+type Icon struct {
+	Rel, Type, Sizes string
+	Href string
+}
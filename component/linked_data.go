@@ -0,0 +1,175 @@
+package component
+
+import (
+	"net/url"
+	"time"
+)
+
+// schema.org structured data, embedded as JSON-LD.
+// @from: https://schema.org/BlogPosting
+
+type (
+	ldPerson struct {
+		Type string `json:"@type"`
+		Name string `json:"name"`
+		EMail string `json:"email,omitempty"`
+	}
+	ldBlogPosting struct {
+		Context string `json:"@context,omitempty"`
+		Type string `json:"@type"`
+		Headline string `json:"headline"`
+		Description string `json:"description,omitempty"`
+		URL string `json:"url,omitempty"`
+		DatePublished string `json:"datePublished,omitempty"`
+		DateModified string `json:"dateModified,omitempty"`
+		Author *ldPerson `json:"author,omitempty"`
+		Image string `json:"image,omitempty"`
+		Keywords string `json:"keywords,omitempty"`
+	}
+	ldListItem struct {
+		Type string `json:"@type"`
+		Position int `json:"position"`
+		URL string `json:"url"`
+	}
+	ldItemList struct {
+		Type string `json:"@type"`
+		Items []ldListItem `json:"itemListElement"`
+	}
+	ldCollectionPage struct {
+		Context string `json:"@context"`
+		Type string `json:"@type"`
+		Name string `json:"name"`
+		URL string `json:"url,omitempty"`
+		MainEntity ldItemList `json:"mainEntity"`
+	}
+	ldBlog struct {
+		Type string `json:"@type"`
+		Name string `json:"name"`
+		Description string `json:"description,omitempty"`
+		URL string `json:"url,omitempty"`
+		Author *ldPerson `json:"author,omitempty"`
+		Posts []ldBlogPosting `json:"blogPost,omitempty"`
+	}
+	ldGraph struct {
+		Context string `json:"@context"`
+		Graph []any `json:"@graph"`
+	}
+)
+
+func ldDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func ldAuthor(a Author) *ldPerson {
+	if a.Name == "" {
+		return nil
+	}
+	return &ldPerson{Type: "Person", Name: a.Name, EMail: a.EMail}
+}
+
+// ldURL resolves the site relative link against the url of the page.
+func ldURL(page, link string) string {
+	base, err := url.Parse(page)
+	if err != nil || page == "" {
+		return link
+	}
+	ref, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+	return base.ResolveReference(ref).String()
+}
+
+// LinkedData describes a published entry as a BlogPosting, and a listing
+// (e.g. a tag page) as a CollectionPage of the posts or tags it lists.
+// Other pages (like search and 404) have none, nil.
+func (b EntryData) LinkedData() any {
+	if b.Meta.Published.IsZero() {
+		return b.collectionPage()
+	}
+	post := ldBlogPosting{
+		Context: "https://schema.org",
+		Type: "BlogPosting",
+		Headline: b.Title,
+		Description: b.Meta.Description,
+		URL: b.Meta.CanonicalURL,
+		DatePublished: ldDate(b.Meta.Published),
+		DateModified: ldDate(b.Meta.Published),
+		Author: ldAuthor(b.Author),
+		Image: b.Meta.Image,
+		Keywords: b.Tags.KeywordList(),
+	}
+	if b.Meta.IsRevised() {
+		post.DateModified = ldDate(b.Meta.LastRevised())
+	}
+	return post
+}
+
+func (b EntryData) collectionPage() any {
+	if b.Meta.NoIndex {
+		return nil
+	}
+	list := ldItemList{Type: "ItemList"}
+	add := func(link string) {
+		list.Items = append(list.Items, ldListItem{
+			Type: "ListItem",
+			Position: len(list.Items)+1,
+			URL: link,
+		})
+	}
+	for _, c := range b.Content {
+		switch c := c.(type) {
+		case PostList:
+			for _, p := range c.Posts {
+				add(p.URL)
+			}
+		case TagCloud:
+			for _, t := range c.Tags {
+				add(ldURL(b.Meta.CanonicalURL, t.Tag.Link()))
+			}
+		}
+	}
+	if len(list.Items) == 0 {
+		return nil
+	}
+	return ldCollectionPage{
+		Context: "https://schema.org",
+		Type: "CollectionPage",
+		Name: b.Title,
+		URL: b.Meta.CanonicalURL,
+		MainEntity: list,
+	}
+}
+
+func (d IndexData) LinkedData() any {
+	blog := ldBlog{
+		Type: "Blog",
+		Name: d.BlogName,
+		Description: d.Description,
+		URL: d.Meta.CanonicalURL,
+		Author: ldAuthor(d.Author),
+	}
+	list := ldItemList{Type: "ItemList"}
+	for _, l := range d.Lists {
+		for _, p := range l.Posts {
+			blog.Posts = append(blog.Posts, ldBlogPosting{
+				Type: "BlogPosting",
+				Headline: p.Title,
+				URL: p.URL,
+				DatePublished: ldDate(p.Published),
+			})
+			list.Items = append(list.Items, ldListItem{
+				Type: "ListItem",
+				Position: len(list.Items)+1,
+				URL: p.URL,
+			})
+		}
+	}
+	return ldGraph{
+		Context: "https://schema.org",
+		Graph: []any{blog, list},
+	}
+}
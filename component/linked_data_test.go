@@ -0,0 +1,59 @@
+package component
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEntryLinkedData(t *testing.T) {
+	posts := PostList{Posts: []PostItem{
+		{URL: "https://example.com/a.html"},
+		{URL: "https://example.com/b.html"},
+	}}
+	tests := []struct {
+		name string
+		data EntryData
+		want string // @type, empty for none
+		items int
+	}{
+		{"entry", EntryData{Title: "A", Meta: Meta{Published: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)}}, "BlogPosting", 0},
+		{"tag page", EntryData{Title: "Tagged x", Content: []ContentElement{posts}}, "CollectionPage", 2},
+		{"tags", EntryData{
+			Title: "Tags",
+			Meta: Meta{CanonicalURL: "https://example.com/tags/"},
+			Content: []ContentElement{TagCloud{Tags: []TagStat{{Tag: "go"}}}},
+		}, "CollectionPage", 1},
+		{"not found", EntryData{Title: "Page not found", Meta: Meta{NoIndex: true}, Content: []ContentElement{posts}}, "", 0},
+		{"search", EntryData{Title: "Search", Meta: Meta{NoIndex: true}, Content: []ContentElement{SearchBox{}}}, "", 0},
+		{"page", EntryData{Title: "About", Content: []ContentElement{SearchBox{}}}, "", 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ld := test.data.LinkedData()
+			switch ld := ld.(type) {
+			case nil:
+				if test.want != "" {
+					t.Errorf("got no linked data, want %s", test.want)
+				}
+			case ldBlogPosting:
+				if test.want != ld.Type {
+					t.Errorf("got %s, want %q", ld.Type, test.want)
+				}
+			case ldCollectionPage:
+				if test.want != ld.Type {
+					t.Errorf("got %s, want %q", ld.Type, test.want)
+				}
+				if len(ld.MainEntity.Items) != test.items {
+					t.Errorf("got %d items, want %d", len(ld.MainEntity.Items), test.items)
+				}
+			default:
+				t.Errorf("unexpected linked data: %#v", ld)
+			}
+		})
+	}
+
+	ld := tests[2].data.LinkedData().(ldCollectionPage)
+	if got, want := ld.MainEntity.Items[0].URL, "https://example.com/tags/go/"; got != want {
+		t.Errorf("tag url: got %s, want %s", got, want)
+	}
+}
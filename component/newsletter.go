@@ -0,0 +1,21 @@
+package component
+
+import "io"
+
+// Digest is an issue of the newsletter, the entries published since the
+// last one.
+type Digest struct {
+	BlogName string
+	// Absolute url of the site.
+	URL string
+	Subject string
+	Issue int
+	Language string
+	Posts []PostItem
+}
+
+// RenderDigest renders the digest as the html of an email: mail clients
+// ignore stylesheets, so the styles are inline, and the urls absolute.
+func RenderDigest(w io.Writer, digest *Digest) error {
+	return pages().Render(w, "Digest", digest)
+}
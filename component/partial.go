@@ -0,0 +1,50 @@
+package component
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"sync/atomic"
+)
+
+// SiteData is what the templates know about the whole site, returned by
+// their Site function.
+type SiteData struct {
+	Name, Description, Language string
+	BaseURL string
+	Author Author
+	// All entries, most recently published first.
+	Posts []PostItem
+	// The entries by tag name, most recently published first.
+	Tags map[string][]PostItem
+	// Of the config, anything the theme wants to be configurable.
+	Params map[string]any
+}
+
+var site atomic.Pointer[SiteData]
+
+func init() {
+	site.Store(&SiteData{})
+}
+
+// SetSite sets what the templates know about the site, call it before
+// rendering its pages.
+func SetSite(data *SiteData) {
+	site.Store(data)
+}
+
+// Partial is a template of the theme included in an entry, rendered with the
+// SiteData, e.g. (partial newsletter-signup).
+type Partial struct {
+	Name string
+}
+
+var _ ContentElement = (*Partial)(nil)
+
+func (p Partial) Render() (template.HTML, error) {
+	buf := &bytes.Buffer{}
+	if err := pages().Render(buf, p.Name, site.Load()); err != nil {
+		return "", fmt.Errorf("partial %s: %w", p.Name, err)
+	}
+	return template.HTML(buf.String()), nil
+}
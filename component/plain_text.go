@@ -0,0 +1,55 @@
+package component
+
+import "strings"
+
+// PlainText returns the text of the content, without any markup.
+// Paragraphs are separated by empty lines.
+func PlainText(content []ContentElement) string {
+	var ps []string
+	for _, c := range content {
+		if t := plainText(c); t != "" {
+			ps = append(ps, t)
+		}
+	}
+	return strings.Join(ps, "\n\n")
+}
+
+func plainText(c ContentElement) string {
+	switch c := c.(type) {
+	case Text:
+		return string(c)
+	case *Paragraph:
+		t := ""
+		for _, part := range c.Content {
+			t += plainText(part)
+		}
+		return t
+	case *Link:
+		return c.Text
+	case *Image:
+		return c.Caption
+	case *CodeBlock:
+		return strings.Trim(c.Source, "\n")
+	case *Section:
+		return c.Title + "\n\n" + PlainText(c.Content)
+	default:
+		return ""
+	}
+}
+
+// Walk calls fn for each element of the content, depth first.
+func Walk(content []ContentElement, fn func(ContentElement)) {
+	for _, c := range content {
+		fn(c)
+		switch c := c.(type) {
+		case *Paragraph:
+			Walk(c.Content, fn)
+		case *Section:
+			Walk(c.Content, fn)
+		}
+	}
+}
+
+func (b EntryData) PlainText() string {
+	return PlainText(b.Content)
+}
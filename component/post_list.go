@@ -0,0 +1,39 @@
+package component
+
+import (
+	"bytes"
+	"html/template"
+	"time"
+)
+
+type PostItem struct {
+	Title string
+	// Site relative link and absolute url of the post.
+	Link, URL string
+	Published time.Time
+	Excerpt string
+	Tags Tags
+}
+
+type PostList struct {
+	Title string
+	Posts []PostItem
+}
+
+var _ ContentElement = (*PostList)(nil)
+
+func (l PostList) Render() (template.HTML, error) {
+	buf := &bytes.Buffer{}
+	err := pages().Render(buf, "PostList", l)
+	return template.HTML(buf.String()), err
+}
+
+type SearchBox struct{}
+
+var _ ContentElement = (*SearchBox)(nil)
+
+func (s SearchBox) Render() (template.HTML, error) {
+	buf := &bytes.Buffer{}
+	err := pages().Render(buf, "SearchBox", s)
+	return template.HTML(buf.String()), err
+}
@@ -0,0 +1,20 @@
+package component
+
+import (
+	"bytes"
+	"html/template"
+)
+
+// SearchResults runs the query of the current url against the search index
+// (see site.buildSearch) on the client.
+type SearchResults struct {
+	Index string
+}
+
+var _ ContentElement = (*SearchResults)(nil)
+
+func (s SearchResults) Render() (template.HTML, error) {
+	buf := &bytes.Buffer{}
+	err := pages().Render(buf, "SearchResults", s)
+	return template.HTML(buf.String()), err
+}
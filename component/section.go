@@ -3,6 +3,7 @@ package component
 import (
 	"bytes"
 	"html/template"
+	"strings"
 
 	//"be/lex"
 )
@@ -30,76 +31,89 @@ func (s Section) Render() (template.HTML, error) {
 	case 1:
 		templName = "Subsection"
 	}
-	err := pages.Render(buf, templName, s)
+	err := pages().Render(buf, templName, s)
 	return template.HTML(buf.String()), err
 }
 
-const HtmlSection = `
-{{ define "Section" }}
-<section id="{{.ID}}">
-	<h2><a href="#{{.ID}}">{{.Title}}</a></h2>
-	{{ range .Content }}
-		{{ Render . }}
-	{{ end }}
-</section>
-{{ end }}
-`
-
-const HtmlSubsection = `
-{{ define "Subsection" }}
-<section id="{{.ID}}">
-	<h3><a href="#{{.ID}}">{{.Title}}</a></h3>
-	{{ range .Content }}
-		{{ Render . }}
-	{{ end }}
-</section>
-{{ end }}
-`
-
+// Text is inline, it only ever appears as part of a Paragraph.
 type Text string
 
 var _ ContentElement = (*Text)(nil)
 
 func (t Text) Render() (template.HTML, error) {
+	return template.HTML(template.HTMLEscapeString(string(t))), nil
+}
+
+// Paragraph is a run of Text interspersed with inline elements (e.g. Link).
+type Paragraph struct {
+	Content []ContentElement
+	// the last element was inline, text that follows continues the paragraph
+	glue bool
+}
+
+var _ ContentElement = (*Paragraph)(nil)
+
+func (p Paragraph) Render() (template.HTML, error) {
 	buf := &bytes.Buffer{}
-	err := pages.Render(buf, "Paragraph", t)
+	err := pages().Render(buf, "Paragraph", p)
 	return template.HTML(buf.String()), err
 }
 
-const HtmlParagraph = `
-{{ define "Paragraph" }}
-<p>
-{{ . }}
-</p>
-{{ end }}
-`
+// appendText continues the last paragraph if text directly follows an inline
+// element, otherwise it starts a new paragraph.
+//
+// @fixme: the tokenizer trims the whitespace around forms, so there is no
+// way of knowing whether the author had a space (or a paragraph break!)
+// between text and inline element. We guess: always a space, except before
+// punctuation.
+func appendText(blog *EntryData, text string) {
+	if p := lastParagraph(blog); p != nil && p.glue {
+		if text != "" && !strings.ContainsAny(text[:1], ".,;:!?)") {
+			text = " " + text
+		}
+		p.Content = append(p.Content, Text(text))
+		p.glue = false
+		return
+	}
+	blog.Content = append(blog.Content, &Paragraph{Content: []ContentElement{Text(text)}})
+}
+
+// appendInline adds el to the end of the last paragraph.
+func appendInline(blog *EntryData, el ContentElement) {
+	p := lastParagraph(blog)
+	if p == nil {
+		p = &Paragraph{}
+		blog.Content = append(blog.Content, p)
+	} else if t, ok := p.Content[len(p.Content)-1].(Text); ok && !strings.HasSuffix(string(t), "(") {
+		p.Content = append(p.Content, Text(" "))
+	}
+	p.Content = append(p.Content, el)
+	p.glue = true
+}
+
+func lastParagraph(blog *EntryData) *Paragraph {
+	if len(blog.Content) == 0 {
+		return nil
+	}
+	p, _ := blog.Content[len(blog.Content)-1].(*Paragraph)
+	return p
+}
 
 type Link struct {
 	Link string
+	Text string
+	// Slug of the entry the link points to, resolved to Link during the
+	// build.
+	Ref string
 	External bool
+	// Offset (in runes) of the (link) form in the source.
+	Pos int
 }
 
 var _ ContentElement = (*Link)(nil)
 
 func (l Link) Render() (template.HTML, error) {
 	buf := &bytes.Buffer{}
-	err := pages.Render(buf, "Link", l)
+	err := pages().Render(buf, "Link", l)
 	return template.HTML(buf.String()), err
 }
-
-const HtmlLink = `
-{{ define "Link" }}
-<a href="{{.Link}}" {{ if .External }} target="_blank" {{ end }}>{{.Text}}</a>
-{{ end }}
-`
-
-const HtmlAside = `
-{{ define "Aside" }}
-<aside>
-	{{ range .Content }}
-		{{ Render . }}
-	{{ end }}
-</aside>
-{{ end }}
-`
-
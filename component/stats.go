@@ -0,0 +1,27 @@
+package component
+
+import (
+	"io"
+	"time"
+)
+
+// Stats are the visits of the site, counted from the logs of the server.
+type Stats struct {
+	BlogName string
+	From, To time.Time
+	Views, Visitors, Bots int
+	Pages, Referrers, Days []StatsRow
+}
+
+type StatsRow struct {
+	Label string
+	Count int
+	// Of the largest count of the table, from 0 to 100.
+	Percent int
+}
+
+// RenderStats renders the stats page, it is not published (and asks to not
+// be indexed if it is anyway).
+func RenderStats(w io.Writer, stats *Stats) error {
+	return pages().Render(w, "Stats", stats)
+}
@@ -0,0 +1,27 @@
+package component
+
+import (
+	"bytes"
+	"html/template"
+	"time"
+)
+
+type TagStat struct {
+	Tag Tag
+	Count int
+	First, Last time.Time
+	// 1 (least used) to 5 (most used)
+	Weight int
+}
+
+type TagCloud struct {
+	Tags []TagStat
+}
+
+var _ ContentElement = (*TagCloud)(nil)
+
+func (c TagCloud) Render() (template.HTML, error) {
+	buf := &bytes.Buffer{}
+	err := pages().Render(buf, "TagCloud", c)
+	return template.HTML(buf.String()), err
+}
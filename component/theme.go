@@ -0,0 +1,117 @@
+package component
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// The default theme, the templates of all pages and their parts. The
+// layouts of the pages are
+//   - base.html: the parts shared by all pages (head, alternates)
+//   - post.html: Entry, the page of an entry
+//   - tag.html: Tag, the page listing the entries of a tag
+//   - index.html: Index, the front page
+//
+// Each file in partials/ is a template of its own, named after the file:
+// partials/post-card.html is included with {{ template "post-card" . }}.
+// The default partials are
+//   - header: the navigation at the top of every page
+//   - footer: the bottom of every page
+//   - post-card: a PostItem, in the lists of entries
+//
+//go:embed theme/*.html theme/partials/*.html
+var defaultTheme embed.FS
+
+var current atomic.Pointer[Template]
+
+// pages are the templates of the theme loaded last.
+func pages() *Template {
+	return current.Load()
+}
+
+func init() {
+	if err := LoadTheme(""); err != nil {
+		panic(err)
+	}
+}
+
+// LoadTheme renders the pages with the templates (html/template) of the
+// theme directory: its .html files (and those in its partials/) replace
+// those of the default theme with the same name, and may add templates of
+// their own (e.g. layouts picked by entries, see EntryData.Layout). The
+// default theme is used as it is if dir is empty.
+// It is safe to call while pages are rendered, but those may be rendered
+// partly with the old and partly with the new theme: the elements of a page
+// use the templates current when they are rendered.
+//
+// Besides Render and LanguageName, the templates can call
+//   - Site: the SiteData of the site being built
+//   - partial: renders the named template (with data, if given), also for
+//     names that are not constant: {{ partial (print "card-" .Kind) . }}
+func LoadTheme(dir string) error {
+	files := map[string]fs.FS{}
+	defaults, _ := fs.Sub(defaultTheme, "theme")
+	themes := []fs.FS{defaults}
+	if dir != "" {
+		if _, err := os.Stat(dir); err != nil {
+			return fmt.Errorf("theme: %w", err)
+		}
+		themes = append(themes, os.DirFS(dir))
+	}
+	for _, theme := range themes {
+		for _, pattern := range []string{"*.html", "partials/*.html"} {
+			names, err := fs.Glob(theme, pattern)
+			if err != nil {
+				return err
+			}
+			for _, name := range names {
+				files[name] = theme
+			}
+		}
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	t := template.New("")
+	t.Funcs(template.FuncMap{
+		"Render": Render,
+		"LanguageName": LanguageName,
+		"Site": func() *SiteData {
+			return site.Load()
+		},
+		"partial": func(name string, data ...any) (template.HTML, error) {
+			buf := &bytes.Buffer{}
+			var d any
+			if len(data) > 0 {
+				d = data[0]
+			}
+			err := t.ExecuteTemplate(buf, name, d)
+			return template.HTML(buf.String()), err
+		},
+	})
+	for _, name := range names {
+		bs, err := fs.ReadFile(files[name], name)
+		if err != nil {
+			return err
+		}
+		tmpl := name
+		if dir, file := path.Split(name); dir == "partials/" {
+			tmpl = strings.TrimSuffix(file, ".html")
+		}
+		if _, err := t.New(tmpl).Parse(string(bs)); err != nil {
+			return fmt.Errorf("theme: %w", err)
+		}
+	}
+	current.Store(&Template{t})
+	return nil
+}
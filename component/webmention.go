@@ -0,0 +1,25 @@
+package component
+
+import "time"
+
+// Mention is a reaction to an entry posted on another site, received as a
+// webmention.
+type Mention struct {
+	AuthorName, AuthorURL string
+	// Of the reaction itself.
+	URL string
+	Published time.Time
+	// Plain text of replies and mentions, shortened.
+	Content string
+}
+
+// Mentions of an entry, by kind of reaction, oldest first.
+type Mentions struct {
+	Likes, Reposts, Bookmarks []Mention
+	// Replies, and posts that link to the entry.
+	Replies []Mention
+}
+
+func (m Mentions) Empty() bool {
+	return len(m.Likes)+len(m.Reposts)+len(m.Bookmarks)+len(m.Replies) == 0
+}
@@ -1,3 +1,13 @@
 module be
 
 go 1.22.1
+
+require (
+	github.com/alecthomas/chroma/v2 v2.14.0
+	golang.org/x/image v0.20.0
+)
+
+require (
+	github.com/dlclark/regexp2 v1.11.0 // indirect
+	golang.org/x/text v0.18.0 // indirect
+)
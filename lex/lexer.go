@@ -1,10 +1,17 @@
 package lex
 
 import (
+	"errors"
 	"fmt"
 	"be/tok"
 )
 
+// Kinds of errors of the lexer, reported as *tok.Error.
+var (
+	ErrUnterminatedForm = errors.New("unterminated form")
+	ErrUnexpectedFormEnd = errors.New("unexpected end of form")
+)
+
 // Input:
 // (title Hello World)
 
@@ -104,6 +111,8 @@ type Node struct {
 	Atom Atom  // TypeAtom
 	Text Text  // TypeText
 	Form *LLHead // TypeForm
+	// Offset (in runes) of the token in the source.
+	Pos int
 }
 
 func (n Node) String() string {
@@ -143,13 +152,19 @@ func (t Text) StringIndent(level int) string {
 	return tabs(level) + fmt.Sprintf("Text(%s)", tok.VisibleString(string(t)))
 }
 
-func Lex(tokens []tok.Token) *LLHead {
+// Lex groups the tokens into forms. It is safe for concurrent use, the forms
+// are new for every call. There is no parser to reuse: the forms are only
+// read by their evaluation (see component.Eval), which can happen any number
+// of times, also concurrently.
+func Lex(tokens []tok.Token) (*LLHead, error) {
 	root := &LLHead{}
 	root.Append(&Node{
 		Type: TypeAtom,
 		Atom: "root",
 	})
 	forms := []*LLHead{root}
+	// of the open forms, for those missing their end
+	starts := []int{}
 	for _, t := range tokens {
 		top := forms[len(forms)-1]
 		switch t.Type {
@@ -158,26 +173,37 @@ func Lex(tokens []tok.Token) *LLHead {
 			form := &Node{
 				Type: TypeForm,
 				Form: head,
+				Pos: t.Pos,
 			}
 			top.Append(form)
 			forms = append(forms, head)
+			starts = append(starts, t.Pos)
 		case tok.TypeAtom:
 			atom := &Node{
 				Type: TypeAtom,
 				Atom: Atom(t.Text),
+				Pos: t.Pos,
 			}
 			top.Append(atom)
 		case tok.TypeText:
 			text := &Node{
 				Type: TypeText,
 				Text: Text(t.Text),
+				Pos: t.Pos,
 			}
 			top.Append(text)
 		case tok.TypeFormEnd:
+			if len(starts) == 0 {
+				return root, tok.Errorf(ErrUnexpectedFormEnd, t.Pos, "unexpected `)`, no form to end")
+			}
 			forms = forms[:len(forms)-1]
+			starts = starts[:len(starts)-1]
 		default:
 			panic("invalid token")
 		}
 	}
-	return root
+	if len(starts) > 0 {
+		return root, tok.Errorf(ErrUnterminatedForm, starts[len(starts)-1], "unterminated form (missing `)`)")
+	}
+	return root, nil
 }
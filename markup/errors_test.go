@@ -0,0 +1,33 @@
+package markup
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseErrors(t *testing.T) {
+	for _, tt := range []struct {
+		src string
+		kind error
+		line, column int
+	}{
+		{"(title a\\q)", ErrInvalidEscape, 1, 9},
+		{"(title a)\n(body \\+ raw", ErrUnterminatedRaw, 2, 7},
+		{"(title a\n(body b)", ErrUnterminatedForm, 1, 1},
+		{"(title a))", ErrUnexpectedFormEnd, 1, 10},
+		{"(title a)\n\n  (titel b)", ErrUnknownForm, 3, 4},
+		{"(title)", ErrMissingArgument, 1, 2},
+		{"(title (t a))", ErrInvalidArgument, 1, 8},
+		{"(title a)\n(published 2024-13-01)", ErrInvalidDate, 2, 2},
+	} {
+		_, err := Parse([]rune(tt.src))
+		var e *Error
+		if !errors.As(err, &e) {
+			t.Errorf("%q: got %v, want an *Error", tt.src, err)
+			continue
+		}
+		if !errors.Is(err, tt.kind) || e.Line != tt.line || e.Column != tt.column {
+			t.Errorf("%q: got %v at %d:%d, want %v at %d:%d", tt.src, e.Kind, e.Line, e.Column, tt.kind, tt.line, tt.column)
+		}
+	}
+}
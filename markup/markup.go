@@ -0,0 +1,77 @@
+// Package markup reads the be markup language, for use outside of the site
+// (see package site for building one).
+//
+// The source is tokenized (package tok), the tokens grouped into forms
+// (package lex), and the forms evaluated into the entry (package component):
+//
+//	data, err := markup.Parse(src)
+//	...
+//	err = component.RenderEntry(w, data)
+//
+// Parse and Render are safe for concurrent use.
+//
+// The errors in the source are reported as *Error, of one of the kinds
+// below:
+//
+//	var e *markup.Error
+//	if errors.As(err, &e) && errors.Is(e, markup.ErrUnknownForm) {
+//		fmt.Printf("line %d: %s\n", e.Line, e.Msg)
+//	}
+package markup
+
+import (
+	"io"
+
+	"be/component"
+	"be/lex"
+	"be/tok"
+)
+
+// Error is an error at a position in the source.
+type Error = tok.Error
+
+// Kinds of errors in the source.
+var (
+	ErrInvalidEscape = tok.ErrInvalidEscape
+	ErrUnterminatedEscape = tok.ErrUnterminatedEscape
+	ErrUnterminatedRaw = tok.ErrUnterminatedRaw
+	ErrUnexpectedCharacter = tok.ErrUnexpectedCharacter
+	ErrUnterminatedForm = lex.ErrUnterminatedForm
+	ErrUnexpectedFormEnd = lex.ErrUnexpectedFormEnd
+	ErrUnknownForm = component.ErrUnknownForm
+	ErrMissingArgument = component.ErrMissingArgument
+	ErrInvalidArgument = component.ErrInvalidArgument
+	ErrInvalidDate = component.ErrInvalidDate
+)
+
+// Parse evaluates the source of an entry. The errors in it have their line
+// and column filled in, but no file name.
+func Parse(src []rune) (*component.EntryData, error) {
+	data, err := parse(src)
+	if err != nil {
+		return nil, tok.Locate(err, "", src)
+	}
+	return data, nil
+}
+
+func parse(src []rune) (*component.EntryData, error) {
+	tokens, err := tok.Tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	root, err := lex.Lex(tokens)
+	if err != nil {
+		return nil, err
+	}
+	return component.Eval(root)
+}
+
+// Render writes the page of the entry as it is, without what the site adds
+// to it (resolved links between entries, processed images, the assets, ...).
+func Render(w io.Writer, src []rune) error {
+	data, err := Parse(src)
+	if err != nil {
+		return err
+	}
+	return component.RenderEntry(w, data)
+}
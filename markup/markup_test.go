@@ -0,0 +1,136 @@
+package markup
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"be/component"
+)
+
+const source = `(title Hello World)
+(published 2024-01-02)
+(body
+Some text (link (url /x) (text a link)).
+)`
+
+func TestConcurrent(t *testing.T) {
+	want := &bytes.Buffer{}
+	if err := Render(want, []rune(source)); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for range 16 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			data, err := Parse([]rune(source))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if data.Title != "Hello World" {
+				t.Errorf("title: got %q", data.Title)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			got := &bytes.Buffer{}
+			if err := Render(got, []rune(source)); err != nil {
+				t.Error(err)
+				return
+			}
+			if got.String() != want.String() {
+				t.Error("concurrent render differs")
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestParse(t *testing.T) {
+	for _, tt := range []struct {
+		name, src string
+		check func(t *testing.T, data *component.EntryData)
+	}{
+		{"title", "(title Hello   World)", func(t *testing.T, data *component.EntryData) {
+			if data.Title != "Hello World" {
+				t.Errorf("title %q", data.Title)
+			}
+		}},
+		{"nbsp and ellipsis", "(title van~Loo...)", func(t *testing.T, data *component.EntryData) {
+			if data.Title != "van Loo…" {
+				t.Errorf("title %q", data.Title)
+			}
+		}},
+		{"meta", "(title x)\n(published 2024-01-02)\n(tags a b)\n(language de)", func(t *testing.T, data *component.EntryData) {
+			if want := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC); !data.Meta.Published.Equal(want) {
+				t.Errorf("published %v", data.Meta.Published)
+			}
+			if len(data.Tags) != 2 || data.Tags[0] != "a" || data.Tags[1] != "b" {
+				t.Errorf("tags %v", data.Tags)
+			}
+			if data.Meta.Language != "de" {
+				t.Errorf("language %q", data.Meta.Language)
+			}
+		}},
+		{"paragraphs", "(body\nfirst\nline\n\nsecond)", func(t *testing.T, data *component.EntryData) {
+			if got := component.PlainText(data.Content); !strings.Contains(got, "first line") || !strings.Contains(got, "second") {
+				t.Errorf("text %q", got)
+			}
+		}},
+		{"link", "(body see (link (url https://example.org) (text the example)))", func(t *testing.T, data *component.EntryData) {
+			var links []*component.Link
+			component.Walk(data.Content, func(c component.ContentElement) {
+				if l, ok := c.(*component.Link); ok {
+					links = append(links, l)
+				}
+			})
+			if len(links) != 1 || links[0].Link != "https://example.org" || links[0].Text != "the example" || !links[0].External {
+				t.Errorf("links %+v", links)
+			}
+		}},
+		{"raw text", `(body (code (lang go) (text \+fmt.Println("(")\+)))`, func(t *testing.T, data *component.EntryData) {
+			if len(data.Content) != 1 {
+				t.Fatalf("content %v", data.Content)
+			}
+			if code, ok := data.Content[0].(*component.CodeBlock); !ok || code.Language != "go" || code.Source != `fmt.Println("(")` {
+				t.Errorf("code %+v", data.Content[0])
+			}
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Parse([]rune(tt.src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, data)
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	for _, tt := range []struct {
+		name, src string
+		want []string
+	}{
+		{"title", "(title Hello World)", []string{`<h1 class="p-name">Hello World</h1>`, "<title>Hello World"}},
+		{"escaped", "(title a < b)\n(body 1 & 2)", []string{"a &lt; b", "1 &amp; 2"}},
+		{"paragraphs", "(body\nfirst\n\nsecond)", []string{"<p>\nfirst\n</p>", "<p>\nsecond\n</p>"}},
+		{"link", "(body (link (url https://example.org) (text x)))", []string{`<a href="https://example.org"  target="_blank" >x</a>`}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			if err := Render(buf, []rune(tt.src)); err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("missing %q in\n%s", want, buf)
+				}
+			}
+		})
+	}
+}
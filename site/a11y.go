@@ -0,0 +1,197 @@
+package site
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"be/component"
+)
+
+var (
+	tagPattern = regexp.MustCompile(`^<(/?)([a-zA-Z][a-zA-Z0-9]*)((?:[^>"']|"[^"]*"|'[^']*')*)>`)
+	attrPattern = regexp.MustCompile(`([a-zA-Z_:][-a-zA-Z0-9_:.]*)(?:\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'=<>]+)))?`)
+	refreshPattern = regexp.MustCompile(`(?i)<meta[^>]+http-equiv=["']?refresh`)
+)
+
+// Link texts that say nothing about where the link leads to, lowercase.
+var vagueLinkTexts = map[string]bool{
+	"here": true,
+	"click here": true,
+	"this": true,
+	"link": true,
+	"more": true,
+	"read more": true,
+	"hier": true,
+	"mehr": true,
+}
+
+// htmlTag is a start or end tag in a document.
+type htmlTag struct {
+	Name string
+	End bool
+	Attrs map[string]string
+	// byte offset in the document
+	Pos int
+	// text content, for <a> only: the text up to </a>, including the alt
+	// text of images
+	Text string
+}
+
+// CheckAccessibility reports what makes the emitted pages hard to use with
+// a screen reader: images without alt text, skipped heading levels, links
+// that only say "here" and pages without a language.
+// Problems caused by an entry are reported at the form in its source,
+// everything else at the position in the emitted page.
+// Call it after Build.
+func (s *Site) CheckAccessibility() []error {
+	byPath := map[string]*Entry{}
+	for _, e := range s.Entries {
+		byPath[e.Path()] = e
+	}
+	var errs []error
+	for _, p := range s.Outputs() {
+		name, doc := filepath.Join(s.Config.Output, filepath.FromSlash(p)), s.outputs[p]
+		if filepath.Ext(p) != ".html" || refreshPattern.Match(doc) {
+			continue // redirect stubs are not read by anyone
+		}
+		if rel, ok := strings.CutPrefix(p, "public/"); ok {
+			// copied (and maybe minified), the positions in the original
+			// are more useful
+			src := filepath.Join(s.Config.Public, filepath.FromSlash(rel))
+			if bs, err := os.ReadFile(src); err == nil {
+				name, doc = src, bs
+			}
+		}
+		e := byPath[p]
+		report := func(t htmlTag, found func(c component.ContentElement) (int, bool), format string, args ...any) {
+			at := fmt.Sprintf("%s:%s", name, lineCol(doc, t.Pos))
+			if e != nil && found != nil {
+				component.Walk(e.Data.Content, func(c component.ContentElement) {
+					if pos, ok := found(c); ok {
+						at = e.Position(pos)
+					}
+				})
+			}
+			errs = append(errs, fmt.Errorf("%s: %s", at, fmt.Sprintf(format, args...)))
+		}
+
+		level := 0
+		for _, t := range htmlTags(doc) {
+			if t.End {
+				continue
+			}
+			switch t.Name {
+			case "html":
+				if strings.TrimSpace(t.Attrs["lang"]) == "" {
+					report(t, nil, "<html> without a lang attribute")
+				}
+			case "img":
+				if _, ok := t.Attrs["alt"]; ok && (t.Attrs["alt"] != "" || t.Attrs["role"] == "presentation" || t.Attrs["aria-hidden"] == "true") {
+					continue
+				}
+				src := t.Attrs["src"]
+				report(t, func(c component.ContentElement) (int, bool) {
+					if img, ok := c.(*component.Image); ok && img.Path == src {
+						return img.Pos, true
+					}
+					return 0, false
+				}, "image without alt text: %s", src)
+			case "h1", "h2", "h3", "h4", "h5", "h6":
+				n := int(t.Name[1] - '0')
+				if n > level+1 {
+					report(t, nil, "<%s> skips heading level h%d", t.Name, level+1)
+				}
+				level = n
+			case "a":
+				text := strings.ToLower(strings.Trim(strings.Join(strings.Fields(t.Text), " "), ".,:;!?…()"))
+				if !vagueLinkTexts[text] {
+					continue
+				}
+				href := t.Attrs["href"]
+				report(t, func(c component.ContentElement) (int, bool) {
+					if link, ok := c.(*component.Link); ok && link.Link == href {
+						return link.Pos, true
+					}
+					return 0, false
+				}, "link text %q does not say where the link leads to: %s", t.Text, href)
+			}
+		}
+	}
+	return errs
+}
+
+// htmlTags scans the tags of a document, skipping comments and the content
+// of scripts and styles. It is no html parser, but good enough for the
+// documents of the templates.
+func htmlTags(doc []byte) []htmlTag {
+	var tags []htmlTag
+	link := -1 // index of the open <a>
+	text := &strings.Builder{}
+	for i := 0; i < len(doc); {
+		if doc[i] != '<' {
+			end := bytes.IndexByte(doc[i:], '<')
+			if end < 0 {
+				end = len(doc) - i
+			}
+			if link >= 0 {
+				text.WriteString(html.UnescapeString(string(doc[i : i+end])))
+			}
+			i += end
+			continue
+		}
+		if bytes.HasPrefix(doc[i:], []byte("<!--")) {
+			end := bytes.Index(doc[i:], []byte("-->"))
+			if end < 0 {
+				break
+			}
+			i += end + len("-->")
+			continue
+		}
+		m := tagPattern.FindSubmatchIndex(doc[i:])
+		if m == nil {
+			i++ // a lone <, or a doctype
+			continue
+		}
+		t := htmlTag{
+			Name: strings.ToLower(string(doc[i+m[4] : i+m[5]])),
+			End: m[3] > m[2],
+			Attrs: map[string]string{},
+			Pos: i,
+		}
+		for _, a := range attrPattern.FindAllSubmatch(doc[i+m[6]:i+m[7]], -1) {
+			t.Attrs[strings.ToLower(string(a[1]))] = html.UnescapeString(string(a[2]) + string(a[3]) + string(a[4]))
+		}
+		i += m[1]
+		switch {
+		case t.Name == "a" && !t.End:
+			link = len(tags)
+			text.Reset()
+		case t.Name == "a" && link >= 0:
+			tags[link].Text = text.String()
+			link = -1
+		case t.Name == "img" && link >= 0:
+			text.WriteString(t.Attrs["alt"])
+		case (t.Name == "script" || t.Name == "style") && !t.End:
+			end := indexEndTag(string(doc[i:]), t.Name)
+			if end < 0 {
+				end = len(doc) - i
+			}
+			i += end
+		}
+		tags = append(tags, t)
+	}
+	return tags
+}
+
+// lineCol formats the byte offset into the document as line:column.
+func lineCol(doc []byte, pos int) string {
+	line := bytes.Count(doc[:pos], []byte("\n")) + 1
+	start := bytes.LastIndexByte(doc[:pos], '\n') + 1
+	return fmt.Sprintf("%d:%d", line, utf8.RuneCount(doc[start:pos])+1)
+}
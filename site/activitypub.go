@@ -0,0 +1,249 @@
+package site
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/url"
+	"os"
+	"time"
+)
+
+type ActivityPub struct {
+	// Name of the account the blog can be followed as on the fediverse:
+	// @<username>@<host of the base url>. Nothing is generated if empty.
+	Username string `json:"username"`
+	// Url of the inbox follow requests are sent to. Served by the shim, the
+	// only part that is not static: it accepts follows, keeps the followers
+	// and delivers the posts of the outbox to them.
+	Inbox string `json:"inbox"`
+	// Url of the followers collection, if the shim serves one.
+	Followers string `json:"followers"`
+	// File with the public key (PEM) the shim signs its requests with.
+	PublicKey string `json:"public_key"`
+	// Avatar of the account, absolute or relative to the site.
+	Icon string `json:"icon"`
+}
+
+// The files are served as they are, the web server has to send the
+// documents under ap/ as application/activity+json, and the webfinger
+// response as application/jrd+json (it is the same for every ?resource=).
+const (
+	webfingerPath = ".well-known/webfinger"
+	actorPath = "ap/actor.json"
+	outboxPath = "ap/outbox.json"
+)
+
+const activityStreamsContext = "https://www.w3.org/ns/activitystreams"
+
+// @from: https://www.w3.org/TR/activitypub/
+// @from: https://docs.joinmastodon.org/spec/activitypub/
+type (
+	webfinger struct {
+		Subject string `json:"subject"`
+		Aliases []string `json:"aliases"`
+		Links []webfingerLink `json:"links"`
+	}
+	webfingerLink struct {
+		Rel string `json:"rel"`
+		Type string `json:"type"`
+		Href string `json:"href"`
+	}
+
+	apActor struct {
+		Context []string `json:"@context"`
+		ID string `json:"id"`
+		Type string `json:"type"`
+		PreferredUsername string `json:"preferredUsername"`
+		Name string `json:"name"`
+		Summary string `json:"summary"`
+		URL string `json:"url"`
+		Inbox string `json:"inbox"`
+		Outbox string `json:"outbox"`
+		Followers string `json:"followers,omitempty"`
+		Icon *apImage `json:"icon,omitempty"`
+		PublicKey apPublicKey `json:"publicKey"`
+	}
+	apImage struct {
+		Type string `json:"type"`
+		URL string `json:"url"`
+	}
+	apPublicKey struct {
+		ID string `json:"id"`
+		Owner string `json:"owner"`
+		PublicKeyPem string `json:"publicKeyPem"`
+	}
+
+	apOutbox struct {
+		Context string `json:"@context"`
+		ID string `json:"id"`
+		Type string `json:"type"`
+		TotalItems int `json:"totalItems"`
+		OrderedItems []apActivity `json:"orderedItems"`
+	}
+	apActivity struct {
+		Context string `json:"@context,omitempty"`
+		ID string `json:"id"`
+		Type string `json:"type"`
+		Actor string `json:"actor"`
+		Published string `json:"published,omitempty"`
+		To []string `json:"to"`
+		CC []string `json:"cc,omitempty"`
+		Object apNote `json:"object"`
+	}
+	apNote struct {
+		Context string `json:"@context,omitempty"`
+		ID string `json:"id"`
+		Type string `json:"type"`
+		AttributedTo string `json:"attributedTo"`
+		Content string `json:"content"`
+		URL string `json:"url"`
+		Published string `json:"published,omitempty"`
+		Updated string `json:"updated,omitempty"`
+		To []string `json:"to"`
+		CC []string `json:"cc,omitempty"`
+		Tag []apTag `json:"tag,omitempty"`
+	}
+	apTag struct {
+		Type string `json:"type"`
+		Href string `json:"href"`
+		Name string `json:"name"`
+	}
+)
+
+const apPublic = "https://www.w3.org/ns/activitystreams#Public"
+
+// buildActivityPub writes what a Mastodon server looks up to follow the
+// blog: the webfinger response, the actor, and its outbox with a Create
+// activity per entry. Every note is also published on its own (under
+// ap/notes/), the url it is identified by.
+func (s *Site) buildActivityPub() error {
+	cfg := s.Config.ActivityPub
+	if cfg.Username == "" {
+		return nil
+	}
+	base, err := url.Parse(s.Config.BaseURL)
+	if err != nil || base.Host == "" {
+		return fmt.Errorf("activitypub: base_url must be absolute: %s", s.Config.BaseURL)
+	}
+	if cfg.Inbox == "" {
+		return fmt.Errorf("activitypub: no inbox configured")
+	}
+	key, err := os.ReadFile(cfg.PublicKey)
+	if err != nil {
+		return fmt.Errorf("activitypub: public key: %w", err)
+	}
+	actor := s.URL(actorPath)
+
+	if err := s.emitJSON(webfingerPath, webfinger{
+		Subject: fmt.Sprintf("acct:%s@%s", cfg.Username, base.Host),
+		Aliases: []string{actor},
+		Links: []webfingerLink{
+			{Rel: "self", Type: "application/activity+json", Href: actor},
+			{Rel: "http://webfinger.net/rel/profile-page", Type: "text/html", Href: s.URL("index.html")},
+		},
+	}); err != nil {
+		return err
+	}
+
+	a := apActor{
+		Context: []string{activityStreamsContext, "https://w3id.org/security/v1"},
+		ID: actor,
+		Type: "Person",
+		PreferredUsername: cfg.Username,
+		Name: s.Config.BlogName,
+		Summary: "<p>" + html.EscapeString(s.Config.Description) + "</p>",
+		URL: s.URL("index.html"),
+		Inbox: cfg.Inbox,
+		Outbox: s.URL(outboxPath),
+		Followers: cfg.Followers,
+		PublicKey: apPublicKey{ID: actor + "#main-key", Owner: actor, PublicKeyPem: string(key)},
+	}
+	if cfg.Icon != "" {
+		a.Icon = &apImage{Type: "Image", URL: s.absURL(cfg.Icon)}
+	}
+	if err := s.emitJSON(actorPath, a); err != nil {
+		return err
+	}
+
+	outbox := apOutbox{
+		Context: activityStreamsContext,
+		ID: s.URL(outboxPath),
+		Type: "OrderedCollection",
+		OrderedItems: []apActivity{},
+	}
+	var cc []string
+	if cfg.Followers != "" {
+		cc = []string{cfg.Followers}
+	}
+	for _, e := range s.Entries {
+		if e.Data.Meta.Draft {
+			continue
+		}
+		note := apNote{
+			ID: s.URL("ap/notes/" + e.Slug + ".json"),
+			Type: "Note",
+			AttributedTo: actor,
+			Content: noteContent(s.URL(e.Path()), e.Data.Title, e.Data.Meta.Description),
+			URL: s.URL(e.Path()),
+			To: []string{apPublic},
+			CC: cc,
+		}
+		if !e.Data.Meta.Published.IsZero() {
+			note.Published = e.Data.Meta.Published.Format(time.RFC3339)
+		}
+		if e.Data.Meta.IsRevised() {
+			note.Updated = e.Data.Meta.LastRevised().Format(time.RFC3339)
+		}
+		for _, t := range e.Data.Tags {
+			note.Tag = append(note.Tag, apTag{Type: "Hashtag", Href: s.URL(t.Link()), Name: "#" + t.Name()})
+		}
+		own := note
+		own.Context = activityStreamsContext
+		if err := s.emitJSON("ap/notes/"+e.Slug+".json", own); err != nil {
+			return err
+		}
+		outbox.OrderedItems = append(outbox.OrderedItems, apActivity{
+			ID: note.ID + "#create",
+			Type: "Create",
+			Actor: actor,
+			Published: note.Published,
+			To: note.To,
+			CC: note.CC,
+			Object: note,
+		})
+	}
+	outbox.TotalItems = len(outbox.OrderedItems)
+	return s.emitJSON(outboxPath, outbox)
+}
+
+// noteContent is the html of the note announcing an entry.
+func noteContent(link, title, description string) string {
+	content := fmt.Sprintf(`<p><a href="%s">%s</a></p>`, html.EscapeString(link), html.EscapeString(title))
+	if description != "" {
+		content += "<p>" + html.EscapeString(description) + "</p>"
+	}
+	return content
+}
+
+// absURL leaves absolute urls alone, and makes those relative to the site
+// absolute.
+func (s *Site) absURL(u string) string {
+	if parsed, err := url.Parse(u); err == nil && parsed.IsAbs() {
+		return u
+	}
+	return s.URL(u)
+}
+
+func (s *Site) emitJSON(p string, v any) error {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false) // the content of notes is html
+	enc.SetIndent("", "\t")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	s.Emit(p, buf.Bytes())
+	return nil
+}
@@ -0,0 +1,200 @@
+package site
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+)
+
+// Announcement of an entry on a service (mastodon, bluesky).
+type Announcement struct {
+	Service string
+	Entry *Entry
+}
+
+const defaultAnnouncement = `{{.Title}}
+
+{{.Description}}
+
+{{.URL}}{{range .Hashtags}} {{.}}{{end}}`
+
+type (
+	// What the template of the announcements is executed with.
+	announcementData struct {
+		Title, Description, URL string
+		Hashtags []string
+	}
+
+	// service posts the text announcing an entry, and returns the url of
+	// the post.
+	service struct {
+		template string
+		post func(e *Entry, text string) (string, error)
+	}
+
+	// Of an entry on a service.
+	announced struct {
+		Status string `json:"status"`
+		Time time.Time `json:"time"`
+	}
+)
+
+// services are those configured, by name.
+func (s *Site) services() map[string]service {
+	services := map[string]service{}
+	if cfg := s.Config.Mastodon; cfg.Server != "" {
+		services["mastodon"] = service{cfg.Template, func(e *Entry, text string) (string, error) {
+			return postStatus(cfg, e, text)
+		}}
+	}
+	if cfg := s.Config.Bluesky; cfg.Server != "" {
+		services["bluesky"] = service{cfg.Template, newBlueskyPoster(s, cfg)}
+	}
+	return services
+}
+
+// PlanAnnouncements lists what is not announced yet, oldest entries first.
+// Drafts and outdated entries are never announced, and nothing is on a
+// service before anything was (see Config.Announced).
+func (s *Site) PlanAnnouncements() ([]Announcement, error) {
+	state, err := s.loadAnnounced()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	var names []string
+	for name := range s.services() {
+		if _, ok := state[name]; ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var as []Announcement
+	for i := len(s.Entries) - 1; i >= 0; i-- {
+		e := s.Entries[i]
+		for _, name := range names {
+			if _, ok := state[name][e.Slug]; !ok && announceable(e) {
+				as = append(as, Announcement{name, e})
+			}
+		}
+	}
+	return as, nil
+}
+
+func announceable(e *Entry) bool {
+	meta := e.Data.Meta
+	return !meta.Draft && !meta.Outdated && !meta.Published.IsZero() && !meta.Published.After(time.Now())
+}
+
+// Announce posts the announcements, and remembers each right after. On
+// services without any announcements so far, it only remembers the entries
+// published until now, only those published after are announced.
+func (s *Site) Announce(as []Announcement) error {
+	state, err := s.loadAnnounced()
+	if errors.Is(err, fs.ErrNotExist) {
+		state = map[string]map[string]announced{}
+	} else if err != nil {
+		return err
+	}
+	services := s.services()
+	baseline := false
+	for name := range services {
+		if _, ok := state[name]; ok {
+			continue
+		}
+		state[name] = map[string]announced{}
+		for _, e := range s.Entries {
+			if announceable(e) {
+				state[name][e.Slug] = announced{Time: time.Now()}
+			}
+		}
+		baseline = true
+		slog.Info("not announcing the entries published so far", "service", name, "entries", len(state[name]), "state", s.Config.Announced)
+	}
+	if baseline {
+		if err := s.saveAnnounced(state); err != nil {
+			return err
+		}
+	}
+	for _, a := range as {
+		srv, ok := services[a.Service]
+		if !ok {
+			return fmt.Errorf("unknown service: %s", a.Service)
+		}
+		text, err := s.announcement(srv.template, a.Entry)
+		if err != nil {
+			return fmt.Errorf("%s: %s: %w", a.Entry.Source, a.Service, err)
+		}
+		u, err := srv.post(a.Entry, text)
+		if err != nil {
+			return fmt.Errorf("%s: %s: %w", a.Entry.Source, a.Service, err)
+		}
+		state[a.Service][a.Entry.Slug] = announced{Status: u, Time: time.Now()}
+		if err := s.saveAnnounced(state); err != nil {
+			return err
+		}
+		slog.Info("announced", "service", a.Service, "entry", a.Entry.Slug, "status", u)
+	}
+	return nil
+}
+
+// announcement is the text announcing the entry, from the template.
+func (s *Site) announcement(tmpl string, e *Entry) (string, error) {
+	t, err := template.New("announcement").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("template: %w", err)
+	}
+	a := announcementData{
+		Title: e.Data.Title,
+		Description: e.Data.Meta.Description,
+		URL: s.URL(e.Path()),
+	}
+	for _, t := range e.Data.Tags {
+		// hashtags are letters and digits only
+		tag := strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+				return r
+			}
+			return -1
+		}, t.Name())
+		if tag != "" {
+			a.Hashtags = append(a.Hashtags, "#"+tag)
+		}
+	}
+	buf := &bytes.Buffer{}
+	if err := t.Execute(buf, a); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// loadAnnounced maps the services to the entries announced on them, by
+// slug.
+func (s *Site) loadAnnounced() (map[string]map[string]announced, error) {
+	bs, err := os.ReadFile(s.Config.Announced)
+	if err != nil {
+		return nil, err
+	}
+	state := map[string]map[string]announced{}
+	if err := json.Unmarshal(bs, &state); err != nil {
+		// announcing everything again would be worse than failing
+		return nil, fmt.Errorf("%s: %w", s.Config.Announced, err)
+	}
+	return state, nil
+}
+
+func (s *Site) saveAnnounced(state map[string]map[string]announced) error {
+	bs, err := json.MarshalIndent(state, "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeCache(s.Config.Announced, append(bs, '\n'))
+}
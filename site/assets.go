@@ -0,0 +1,369 @@
+package site
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"be/component"
+)
+
+// buildAssets emits the bundles of the config and sets the stylesheets and
+// scripts linked by every page.
+// Dev builds link the sources instead, which are copied along with the
+// rest of the public directory.
+func (s *Site) buildAssets() error {
+	theme, err := s.buildTheme()
+	if err != nil {
+		return err
+	}
+	s.stylesheets, s.scripts = []component.Asset{theme}, nil
+	names := make([]string, 0, len(s.Config.Bundles))
+	for name := range s.Config.Bundles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		sources := s.Config.Bundles[name]
+		if len(sources) == 0 {
+			continue
+		}
+		var links []component.Asset
+		if s.Config.Dev {
+			for _, src := range sources {
+				bs, err := os.ReadFile(filepath.Join(s.Config.Public, filepath.FromSlash(src)))
+				if err != nil {
+					return err
+				}
+				links = append(links, component.Asset{Href: "/public/" + path.Clean(src), Integrity: integrity(bs)})
+			}
+		} else {
+			bundle, err := s.bundle(name, sources)
+			if err != nil {
+				return err
+			}
+			s.Emit(path.Join("public", name), bundle)
+			links = append(links, component.Asset{Href: "/public/" + name, Integrity: integrity(bundle)})
+		}
+		switch path.Ext(name) {
+		case ".css":
+			s.stylesheets = append(s.stylesheets, links...)
+		case ".js":
+			s.scripts = append(s.scripts, links...)
+		}
+	}
+	return nil
+}
+
+// integrity is the value of the integrity attribute of a stylesheet or
+// script with the given content, the browser refuses to apply it if it was
+// tampered with (e.g. by a CDN).
+// @from: https://developer.mozilla.org/en-US/docs/Web/Security/Subresource_Integrity
+func integrity(data []byte) string {
+	sum := sha512.Sum384(data)
+	return "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func (s *Site) bundle(name string, sources []string) ([]byte, error) {
+	var parts []string
+	for _, src := range sources {
+		bs, err := os.ReadFile(filepath.Join(s.Config.Public, filepath.FromSlash(src)))
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, string(bs))
+	}
+	switch path.Ext(name) {
+	case ".css":
+		css := strings.Join(parts, "\n")
+		if s.Config.Minify {
+			css = minifyCSS(css)
+		}
+		return []byte(css), nil
+	case ".js":
+		// guard against sources relying on automatic semicolon insertion
+		js := strings.Join(parts, ";\n")
+		if s.Config.Minify {
+			js = minifyJS(js)
+		}
+		return []byte(js), nil
+	}
+	return []byte(strings.Join(parts, "\n")), nil
+}
+
+// bundled reports whether the public file (slash separated, relative to the
+// public directory) is only published as part of a bundle.
+func (s *Site) bundled(rel string) bool {
+	return !s.Config.Dev && s.bundleSource(rel)
+}
+
+// bundleSource reports whether the public file (slash separated, relative to
+// the public directory) is one of the sources of a bundle.
+func (s *Site) bundleSource(rel string) bool {
+	for _, sources := range s.Config.Bundles {
+		if slices.Contains(sources, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// minifyCSS drops comments and all whitespace that does not separate
+// anything. Strings are left untouched.
+func minifyCSS(css string) string {
+	var out []rune
+	rs := []rune(css)
+	space := false
+	last := func() rune {
+		if len(out) == 0 {
+			return 0
+		}
+		return out[len(out)-1]
+	}
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		switch {
+		case r == '/' && i+1 < len(rs) && rs[i+1] == '*':
+			i = commentEnd(rs, i)
+			continue
+		case unicode.IsSpace(r):
+			space = true
+			continue
+		}
+		if r == '}' && last() == ';' {
+			out = out[:len(out)-1]
+		}
+		if space && last() != 0 && !strings.ContainsRune("{};:,", last()) && !strings.ContainsRune("{};,", r) {
+			out = append(out, ' ')
+		}
+		space = false
+		if r == '"' || r == '\'' {
+			j := stringEnd(rs, i)
+			out = append(out, rs[i:j]...)
+			i = j - 1
+			continue
+		}
+		out = append(out, r)
+	}
+	return string(out)
+}
+
+// minifyJS drops comments, indentation and empty lines. Line breaks are
+// kept, so that automatic semicolon insertion still works.
+// @fixme: regex literals are not recognized, a quote or a // within one
+// breaks the minification.
+func minifyJS(js string) string {
+	var out []rune
+	rs := []rune(js)
+	lineStart := true
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		switch {
+		case r == '/' && i+1 < len(rs) && rs[i+1] == '/':
+			for i+1 < len(rs) && rs[i+1] != '\n' {
+				i++
+			}
+		case r == '/' && i+1 < len(rs) && rs[i+1] == '*':
+			i = commentEnd(rs, i)
+		case r == '"' || r == '\'' || r == '`':
+			j := stringEnd(rs, i)
+			out = append(out, rs[i:j]...)
+			i = j - 1
+			lineStart = false
+		case r == '\n':
+			for len(out) > 0 && strings.ContainsRune(" \t\r", out[len(out)-1]) {
+				out = out[:len(out)-1]
+			}
+			if len(out) > 0 && out[len(out)-1] != '\n' {
+				out = append(out, '\n')
+			}
+			lineStart = true
+		case lineStart && unicode.IsSpace(r):
+		default:
+			lineStart = false
+			out = append(out, r)
+		}
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// commentEnd returns the index of the slash closing the comment starting at
+// rs[i], or the last index if it is never closed.
+func commentEnd(rs []rune, i int) int {
+	for j := i + 2; j+1 < len(rs); j++ {
+		if rs[j] == '*' && rs[j+1] == '/' {
+			return j + 1
+		}
+	}
+	return len(rs) - 1
+}
+
+// stringEnd returns the index just past the string literal starting at rs[i].
+func stringEnd(rs []rune, i int) int {
+	j := i + 1
+	for ; j < len(rs) && rs[j] != rs[i]; j++ {
+		if rs[j] == '\\' {
+			j++
+		}
+	}
+	return min(j+1, len(rs))
+}
+
+// Elements whose content is published exactly as it is.
+var rawElements = map[string]bool{"pre": true, "code": true, "textarea": true, "script": true, "style": true}
+
+// Elements that are laid out inline, the whitespace around them is (probably)
+// significant.
+var inlineElements = map[string]bool{
+	"a": true, "abbr": true, "b": true, "bdi": true, "bdo": true, "br": true,
+	"button": true, "cite": true, "code": true, "data": true, "dfn": true,
+	"em": true, "i": true, "img": true, "input": true, "kbd": true,
+	"label": true, "mark": true, "picture": true, "q": true, "s": true,
+	"samp": true, "select": true, "small": true, "span": true,
+	"strong": true, "sub": true, "sup": true, "svg": true, "textarea": true,
+	"time": true, "u": true, "var": true, "wbr": true,
+}
+
+// minifyHTML strips comments and collapses whitespace, except within
+// elements like pre and code. Whitespace between two block level tags is
+// dropped entirely.
+func minifyHTML(html string) string {
+	out := &strings.Builder{}
+	prevTag := "" // name of the tag written last, if nothing written since
+	for i := 0; i < len(html); {
+		switch {
+		case strings.HasPrefix(html[i:], "<!--"):
+			end := strings.Index(html[i:], "-->")
+			if end < 0 {
+				return out.String()
+			}
+			i += end + len("-->")
+		case html[i] == '<':
+			end := strings.IndexByte(html[i:], '>')
+			if end < 0 {
+				out.WriteString(html[i:])
+				return out.String()
+			}
+			tag := collapseTag(html[i : i+end+1])
+			out.WriteString(tag)
+			i += end + 1
+			name, closing := tagName(tag)
+			if rawElements[name] && !closing && !strings.HasSuffix(tag, "/>") {
+				end := indexEndTag(html[i:], name)
+				if end < 0 {
+					end = len(html) - i
+				}
+				out.WriteString(html[i : i+end])
+				i += end
+			}
+			prevTag = name
+		case isHTMLSpace(html[i]):
+			j := i
+			for j < len(html) && isHTMLSpace(html[j]) {
+				j++
+			}
+			nextTag := ""
+			if j < len(html) && html[j] == '<' && !strings.HasPrefix(html[j:], "<!--") {
+				nextTag, _ = tagName(html[j:])
+			}
+			atStart := out.Len() == 0 || j == len(html)
+			betweenBlocks := prevTag != "" && !inlineElements[prevTag] && nextTag != "" && !inlineElements[nextTag]
+			if !atStart && !betweenBlocks {
+				out.WriteByte(' ')
+				prevTag = ""
+			}
+			i = j
+		default:
+			out.WriteByte(html[i])
+			prevTag = ""
+			i++
+		}
+	}
+	return out.String()
+}
+
+// indexEndTag returns the offset of the first end tag </name in s (in any
+// case), or -1.
+// The offsets of a lowercased copy of s cannot be used, lowercasing changes
+// the length of some characters.
+func indexEndTag(s, name string) int {
+	for i := 0; ; {
+		j := strings.Index(s[i:], "</")
+		if j < 0 {
+			return -1
+		}
+		i += j
+		if end := i + 2 + len(name); end <= len(s) && strings.EqualFold(s[i+2:end], name) {
+			return i
+		}
+		i += 2
+	}
+}
+
+// collapseTag collapses the whitespace between the attributes of a tag.
+func collapseTag(tag string) string {
+	out := &strings.Builder{}
+	var quote byte
+	for i := 0; i < len(tag); i++ {
+		b := tag[i]
+		switch {
+		case quote != 0:
+			if b == quote {
+				quote = 0
+			}
+		case b == '"' || b == '\'':
+			quote = b
+		case isHTMLSpace(b):
+			for i+1 < len(tag) && isHTMLSpace(tag[i+1]) {
+				i++
+			}
+			b = ' '
+		}
+		out.WriteByte(b)
+	}
+	return out.String()
+}
+
+// isHTMLSpace excludes non-breaking spaces (and works on bytes, not runes).
+func isHTMLSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r' || b == '\f'
+}
+
+// tagName returns the lowercase name of the tag at the start of s.
+func tagName(s string) (name string, closing bool) {
+	s = strings.TrimPrefix(s, "<")
+	if closing = strings.HasPrefix(s, "/"); closing {
+		s = s[1:]
+	}
+	end := strings.IndexFunc(s, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '>' || r == '/'
+	})
+	if end < 0 {
+		end = len(s)
+	}
+	return strings.ToLower(s[:end]), closing
+}
+
+// minifyPages minifies the html pages emitted since the last postprocess.
+func (s *Site) minifyPages() error {
+	for _, p := range s.pendingOutputs() {
+		if path.Ext(p) != ".html" {
+			continue
+		}
+		html := s.outputs[p]
+		minified, err := s.cached("html", func() ([]byte, error) {
+			return []byte(minifyHTML(string(html))), nil
+		}, html)
+		if err != nil {
+			return err
+		}
+		s.outputs[p] = minified
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package site
+
+import "testing"
+
+func TestMinifyHTML(t *testing.T) {
+	for _, tt := range []struct{ in, want string }{
+		{"<p>a   b</p>\n\n<p>c</p>", "<p>a b</p><p>c</p>"},
+		{"<!-- comment --><div>\n  <span>a</span> <b>b</b>\n</div>", "<div> <span>a</span> <b>b</b> </div>"},
+		{"<pre>a  b\n  c</pre>  <p>d</p>", "<pre>a  b\n  c</pre><p>d</p>"},
+		{"<PRE>a  b</Pre><p>  c  </p>", "<PRE>a  b</Pre><p> c </p>"},
+		// lowercased, İ is longer than it is
+		{"<pre>İİ  x\n  y</pre>  <p>z</p>", "<pre>İİ  x\n  y</pre><p>z</p>"},
+		{"<script>if (a  <  b) {}</script>", "<script>if (a  <  b) {}</script>"},
+		{"<a  href=\"x  y\"   class=z>t</a>", "<a href=\"x  y\" class=z>t</a>"},
+	} {
+		if got := minifyHTML(tt.in); got != tt.want {
+			t.Errorf("minifyHTML(%q)\ngot  %q\nwant %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestIndexEndTag(t *testing.T) {
+	for _, tt := range []struct {
+		s, name string
+		want int
+	}{
+		{"a</pre>", "pre", 1},
+		{"İ</PRE>", "pre", 2},
+		{"</p></pre>", "pre", 4},
+		{"</pr", "pre", -1},
+		{"none", "pre", -1},
+	} {
+		if got := indexEndTag(tt.s, tt.name); got != tt.want {
+			t.Errorf("indexEndTag(%q, %q) = %d, want %d", tt.s, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMinifyCSS(t *testing.T) {
+	for _, tt := range []struct{ in, want string }{
+		{"a  {  color: red ; }\n/* comment */ b{x:y}", "a{color:red}b{x:y}"},
+		{`a::after { content: "  /* no comment */  "; }`, `a::after{content:"  /* no comment */  "}`},
+	} {
+		if got := minifyCSS(tt.in); got != tt.want {
+			t.Errorf("minifyCSS(%q)\ngot  %q\nwant %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMinifyJS(t *testing.T) {
+	for _, tt := range []struct{ in, want string }{
+		{"// comment\nlet a = \"x  // y\"; /* z */\nf(a)", "let a = \"x  // y\";\nf(a)"},
+		{"let u = 'http://example.org'", "let u = 'http://example.org'"},
+	} {
+		if got := minifyJS(tt.in); got != tt.want {
+			t.Errorf("minifyJS(%q)\ngot  %q\nwant %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package site
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+type Bluesky struct {
+	// Server (PDS) of the account entries are announced from, e.g.
+	// https://bsky.social. Nothing is announced if empty.
+	Server string `json:"server"`
+	// Handle (or DID) of the account, and an app password of it, best set
+	// from the environment (BLOG_BLUESKY__PASSWORD).
+	Handle string `json:"handle"`
+	Password string `json:"password"`
+	// Like Mastodon.Template, posts are limited to 300 characters.
+	Template string `json:"template"`
+}
+
+// Largest blob a PDS accepts as the thumbnail of a link card.
+const maxBlueskyThumb = 1000000
+
+// @from: https://docs.bsky.app/docs/advanced-guides/posts
+type (
+	bskySession struct {
+		AccessJwt string `json:"accessJwt"`
+		DID string `json:"did"`
+	}
+
+	bskyPost struct {
+		Type string `json:"$type"`
+		Text string `json:"text"`
+		CreatedAt string `json:"createdAt"`
+		Langs []string `json:"langs,omitempty"`
+		Facets []bskyFacet `json:"facets,omitempty"`
+		Embed *bskyEmbed `json:"embed,omitempty"`
+	}
+	bskyFacet struct {
+		Index struct {
+			ByteStart int `json:"byteStart"`
+			ByteEnd int `json:"byteEnd"`
+		} `json:"index"`
+		Features []map[string]string `json:"features"`
+	}
+	bskyEmbed struct {
+		Type string `json:"$type"`
+		External struct {
+			URI string `json:"uri"`
+			Title string `json:"title"`
+			Description string `json:"description"`
+			Thumb json.RawMessage `json:"thumb,omitempty"`
+		} `json:"external"`
+	}
+)
+
+// newBlueskyPoster returns the function posting to the account, it logs in
+// at the first post.
+func newBlueskyPoster(s *Site, cfg Bluesky) func(e *Entry, text string) (string, error) {
+	server := strings.TrimSuffix(cfg.Server, "/")
+	var session *bskySession
+	return func(e *Entry, text string) (string, error) {
+		if session == nil {
+			if cfg.Password == "" {
+				return "", fmt.Errorf("no app password")
+			}
+			session = &bskySession{}
+			err := xrpc(server+"/xrpc/com.atproto.server.createSession", "", map[string]string{
+				"identifier": cfg.Handle,
+				"password": cfg.Password,
+			}, session)
+			if err != nil {
+				session = nil
+				return "", fmt.Errorf("login: %w", err)
+			}
+		}
+		link := s.URL(e.Path())
+		post := bskyPost{
+			Type: "app.bsky.feed.post",
+			Text: text,
+			CreatedAt: time.Now().UTC().Format(time.RFC3339),
+			Langs: []string{e.Data.Meta.Language},
+			Facets: bskyFacets(text, link),
+			Embed: &bskyEmbed{Type: "app.bsky.embed.external"},
+		}
+		post.Embed.External.URI = link
+		post.Embed.External.Title = e.Data.Title
+		post.Embed.External.Description = e.Data.Meta.Description
+		if thumb := s.localOutput(e.Data.Meta.Image); thumb != nil && len(thumb) <= maxBlueskyThumb {
+			var uploaded struct {
+				Blob json.RawMessage `json:"blob"`
+			}
+			req, err := http.NewRequest(http.MethodPost, server+"/xrpc/com.atproto.repo.uploadBlob", bytes.NewReader(thumb))
+			if err != nil {
+				return "", err
+			}
+			req.Header.Set("Authorization", "Bearer "+session.AccessJwt)
+			req.Header.Set("Content-Type", http.DetectContentType(thumb))
+			if err := doJSON(req, &uploaded); err != nil {
+				return "", fmt.Errorf("upload thumbnail: %w", err)
+			}
+			post.Embed.External.Thumb = uploaded.Blob
+		}
+		var created struct {
+			URI string `json:"uri"`
+		}
+		err := xrpc(server+"/xrpc/com.atproto.repo.createRecord", session.AccessJwt, map[string]any{
+			"repo": session.DID,
+			"collection": post.Type,
+			"record": post,
+		}, &created)
+		if err != nil {
+			return "", err
+		}
+		// at://<did>/app.bsky.feed.post/<rkey>
+		return fmt.Sprintf("https://bsky.app/profile/%s/post/%s", session.DID, path.Base(created.URI)), nil
+	}
+}
+
+// bskyFacets marks the link and the hashtags in the text, posts do not
+// detect them on their own. Facets are indexed by byte offsets (of the
+// utf-8 text).
+func bskyFacets(text, link string) []bskyFacet {
+	var facets []bskyFacet
+	add := func(start, end int, feature map[string]string) {
+		f := bskyFacet{Features: []map[string]string{feature}}
+		f.Index.ByteStart, f.Index.ByteEnd = start, end
+		facets = append(facets, f)
+	}
+	if i := strings.Index(text, link); i >= 0 {
+		add(i, i+len(link), map[string]string{"$type": "app.bsky.richtext.facet#link", "uri": link})
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] != '#' {
+			continue
+		}
+		if before, _ := utf8.DecodeLastRuneInString(text[:i]); i > 0 && !unicode.IsSpace(before) {
+			continue // part of a url, ...
+		}
+		end := i + 1
+		for end < len(text) {
+			r, size := utf8.DecodeRuneInString(text[end:])
+			if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+				break
+			}
+			end += size
+		}
+		if end > i+1 {
+			add(i, end, map[string]string{"$type": "app.bsky.richtext.facet#tag", "tag": text[i+1 : end]})
+		}
+	}
+	return facets
+}
+
+// xrpc posts the json body to the method, and decodes the response into v.
+func xrpc(u, token string, body, v any) error {
+	bs, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(bs))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return doJSON(req, v)
+}
+
+// localOutput reads the file of the output directory an absolute url of the
+// site points to, or returns nil.
+func (s *Site) localOutput(u string) []byte {
+	rel, ok := strings.CutPrefix(u, strings.TrimSuffix(s.Config.BaseURL, "/")+"/")
+	if !ok || u == "" {
+		return nil
+	}
+	bs, err := os.ReadFile(filepath.Join(s.Config.Output, filepath.FromSlash(rel)))
+	if err != nil {
+		return nil
+	}
+	return bs
+}
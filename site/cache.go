@@ -0,0 +1,160 @@
+package site
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"be/component"
+	"be/lex"
+	"be/tok"
+)
+
+// buildID identifies the running executable. The templates, the minifiers,
+// etc. are all compiled into it, a cached result computed by a different
+// executable might be outdated.
+// Empty if the executable cannot be read, then nothing is cached.
+var buildID = sync.OnceValue(func() string {
+	exe, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	bs, err := os.ReadFile(exe)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(bs)
+	return hex.EncodeToString(sum[:])
+})
+
+// cached returns the result of build, which is fully determined by the
+// inputs, from the cache directory if an earlier build already computed it.
+// A nil result is never cached.
+func (s *Site) cached(kind string, build func() ([]byte, error), inputs ...[]byte) ([]byte, error) {
+	if buildID() == "" {
+		return build()
+	}
+	h := sha256.New()
+	h.Write([]byte(buildID()))
+	for _, in := range inputs {
+		fmt.Fprintf(h, "%d:", len(in))
+		h.Write(in)
+	}
+	p := filepath.Join(s.Config.Cache, kind, hex.EncodeToString(h.Sum(nil))[:32])
+	if bs, err := os.ReadFile(p); err == nil {
+		s.stats.lookup(kind, true)
+		return bs, nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	slog.Debug("not cached", "kind", kind, "key", filepath.Base(p))
+	bs, err := build()
+	if err != nil || bs == nil {
+		return bs, err
+	}
+	s.stats.lookup(kind, false) // only counted if there is something to cache
+	return bs, writeCache(p, bs)
+}
+
+// gitHead is the current commit, or empty outside of a git repository.
+func gitHead() string {
+	out, err := exec.Command("git", "rev-parse", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// writtenPath is where Write remembers what it wrote, so that it can skip
+// files that are still the same the next time.
+func (s *Site) writtenPath() string {
+	return filepath.Join(s.Config.Cache, "written.json")
+}
+
+// loadWritten maps the output paths written by the last Write into the
+// output directory to the hash of their content.
+func (s *Site) loadWritten() map[string]string {
+	written := map[string]string{}
+	if bs, err := os.ReadFile(s.writtenPath()); err == nil {
+		json.Unmarshal(bs, &written) // start over if corrupted
+	}
+	return written
+}
+
+func (s *Site) saveWritten(written map[string]string) error {
+	bs, err := json.Marshal(written)
+	if err != nil {
+		return err
+	}
+	return writeCache(s.writtenPath(), bs)
+}
+
+// forgetWritten drops what Write remembers about the files below dir, e.g.
+// after it was removed.
+func (s *Site) forgetWritten(dir string) {
+	written := s.loadWritten()
+	for p := range written {
+		if within(dir, p) {
+			delete(written, p)
+		}
+	}
+	if err := s.saveWritten(written); err != nil {
+		slog.Warn("cannot save what was written", "err", err)
+	}
+}
+
+// parse tokenizes and evaluates the source of an entry.
+func parse(src []rune, st *buildStats) (*component.EntryData, error) {
+	done := st.timed("tokenize")
+	tokens, err := tok.Tokenize(src)
+	done()
+	if err != nil {
+		return nil, err
+	}
+	defer st.timed("parse")()
+	root, err := lex.Lex(tokens)
+	if err != nil {
+		return nil, err
+	}
+	return component.Eval(root)
+}
+
+// parseCached parses the source, or decodes the entry parsed from the same
+// source before. Only the parsed source is cached, everything the build adds
+// to the entry is not.
+func (s *Site) parseCached(src []rune, bs []byte) (*component.EntryData, error) {
+	var parsed *component.EntryData
+	encoded, err := s.cached("ast", func() ([]byte, error) {
+		data, err := parse(src, s.stats)
+		if err != nil {
+			return nil, err
+		}
+		parsed = data
+		buf := &bytes.Buffer{}
+		if err := gob.NewEncoder(buf).Encode(data); err != nil {
+			slog.Debug("cannot cache parsed entry", "err", err)
+			return nil, nil // not cached
+		}
+		return buf.Bytes(), nil
+	}, bs)
+	if err != nil || parsed != nil {
+		return parsed, err
+	}
+	data := &component.EntryData{}
+	if err := gob.NewDecoder(bytes.NewReader(encoded)).Decode(data); err != nil {
+		slog.Debug("cannot decode cached entry, parsing it again", "err", err)
+		return parse(src, s.stats)
+	}
+	return data, nil
+}
@@ -0,0 +1,225 @@
+package site
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"be/component"
+)
+
+type LinkCheck struct {
+	// Also request the links to other sites, not only check those within
+	// this one.
+	External bool
+	// Number of requests in flight at most.
+	Concurrency int
+	Timeout time.Duration
+	// How long a link found working is not requested again.
+	MaxAge time.Duration
+}
+
+var anchorPattern = regexp.MustCompile(`\s(?:id|name)=["']?([^"'\s>]+)`)
+
+// CheckLinks reports the links of the entries that point nowhere: to pages
+// (or anchors on them) that the build did not emit, or, if opts.External,
+// to urls that fail to load.
+// Call it after Build, the links are checked against its outputs.
+func (s *Site) CheckLinks(opts LinkCheck) []error {
+	type found struct {
+		e *Entry
+		link *component.Link
+	}
+	var links []found
+	for _, e := range s.Entries {
+		component.Walk(e.Data.Content, func(c component.ContentElement) {
+			if link, ok := c.(*component.Link); ok && link.Link != "" {
+				links = append(links, found{e, link})
+			}
+		})
+	}
+
+	broken := map[string]string{}
+	if opts.External {
+		seen := map[string]bool{}
+		var urls []string
+		for _, l := range links {
+			if u, ok := s.externalURL(l.link.Link); ok && !seen[u] {
+				seen[u] = true
+				urls = append(urls, u)
+			}
+		}
+		sort.Strings(urls)
+		broken = s.checkExternal(urls, opts)
+	}
+
+	anchors := map[string]map[string]bool{}
+	var errs []error
+	for _, l := range links {
+		report := func(reason string) {
+			errs = append(errs, fmt.Errorf("%s: broken link: %s (%s)", l.e.Position(l.link.Pos), l.link.Link, reason))
+		}
+		if u, ok := s.externalURL(l.link.Link); ok {
+			if reason := broken[u]; reason != "" {
+				report(reason)
+			}
+			continue
+		}
+		u, err := url.Parse(l.link.Link)
+		if err != nil {
+			report(err.Error())
+			continue
+		}
+		if u.Scheme != "" {
+			continue // mailto:, etc.
+		}
+		target := u.Path
+		if target == "" {
+			target = l.e.Path() // #anchor on the same page
+		} else if !strings.HasPrefix(target, "/") {
+			target = path.Join(path.Dir("/"+l.e.Path()), target)
+			if strings.HasSuffix(u.Path, "/") {
+				target += "/"
+			}
+		}
+		p, ok := s.outputFor(target)
+		if !ok {
+			report("no such page")
+			continue
+		}
+		if u.Fragment == "" {
+			continue
+		}
+		if anchors[p] == nil {
+			anchors[p] = map[string]bool{}
+			for _, m := range anchorPattern.FindAllSubmatch(s.outputs[p], -1) {
+				anchors[p][string(m[1])] = true
+			}
+		}
+		if !anchors[p][u.Fragment] {
+			report(fmt.Sprintf("no anchor #%s on %s", u.Fragment, p))
+		}
+	}
+	return errs
+}
+
+// externalURL is the link without its fragment, if it points to another
+// site. Links to the site itself by its full url are not external.
+func (s *Site) externalURL(link string) (string, bool) {
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", false
+	}
+	if base := strings.TrimSuffix(s.Config.BaseURL, "/"); base != "" && (link == base || strings.HasPrefix(link, base+"/")) {
+		return "", false
+	}
+	u.Fragment = ""
+	return u.String(), true
+}
+
+// outputFor finds the emitted file served at the site relative path p.
+func (s *Site) outputFor(p string) (string, bool) {
+	p = strings.TrimPrefix(p, "/")
+	candidates := []string{p, p + ".html", path.Join(p, "index.html")}
+	if p == "" || strings.HasSuffix(p, "/") {
+		candidates = []string{p + "index.html"}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range candidates {
+		if _, ok := s.outputs[c]; ok {
+			return c, true
+		}
+	}
+	return "", false
+}
+
+// checkExternal requests the urls, and maps those that fail to the reason.
+func (s *Site) checkExternal(urls []string, opts LinkCheck) map[string]string {
+	client := &http.Client{Timeout: opts.Timeout}
+	checked := s.loadCheckedLinks()
+	broken := map[string]string{}
+	mu := sync.Mutex{}
+	parallelN(opts.Concurrency, len(urls), func(i int) error {
+		u := urls[i]
+		mu.Lock()
+		last, ok := checked[u]
+		mu.Unlock()
+		if ok && time.Since(last) < opts.MaxAge {
+			return nil
+		}
+		err := requestLink(client, u)
+		slog.Debug("checked link", "url", u, "err", err)
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			broken[u] = err.Error()
+			delete(checked, u)
+		} else {
+			checked[u] = time.Now()
+		}
+		return nil
+	})
+	if err := s.saveCheckedLinks(checked); err != nil {
+		slog.Warn("cannot remember checked links", "err", err)
+	}
+	return broken
+}
+
+// requestLink asks for the headers of the url, falling back to a GET for
+// servers that do not support HEAD requests.
+func requestLink(client *http.Client, u string) error {
+	var status int
+	for _, method := range []string{http.MethodHead, http.MethodGet} {
+		req, err := http.NewRequest(method, u, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; blog link checker)")
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		status = resp.StatusCode
+		if status != http.StatusMethodNotAllowed && status != http.StatusNotImplemented && status != http.StatusForbidden {
+			break
+		}
+	}
+	if status >= 400 {
+		return fmt.Errorf("%d %s", status, http.StatusText(status))
+	}
+	return nil
+}
+
+func (s *Site) checkedLinksPath() string {
+	return filepath.Join(s.Config.Cache, "links.json")
+}
+
+// loadCheckedLinks maps the external urls found working to when they were
+// last requested.
+func (s *Site) loadCheckedLinks() map[string]time.Time {
+	checked := map[string]time.Time{}
+	if bs, err := os.ReadFile(s.checkedLinksPath()); err == nil {
+		json.Unmarshal(bs, &checked) // request everything again if corrupted
+	}
+	return checked
+}
+
+func (s *Site) saveCheckedLinks(checked map[string]time.Time) error {
+	bs, err := json.Marshal(checked)
+	if err != nil {
+		return err
+	}
+	return writeCache(s.checkedLinksPath(), bs)
+}
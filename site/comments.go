@@ -0,0 +1,260 @@
+package site
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"be/component"
+)
+
+type Comments struct {
+	// Directory with the comments, in a directory per entry (named by its
+	// slug) with a .json file per comment, see Comment.
+	Dir string `json:"dir"`
+	// Where the comment form under the entries posts to: an endpoint that
+	// stores the submissions (urlencoded or json, to be imported with blog
+	// comments import), or a mailto: address. No form if empty.
+	Endpoint string `json:"endpoint"`
+}
+
+// Comment as stored in a file of the comments directory.
+type Comment struct {
+	Author string `json:"author"`
+	URL string `json:"url,omitempty"`
+	// Never shown, to answer privately.
+	Email string `json:"email,omitempty"`
+	Date time.Time `json:"date"`
+	Text string `json:"text"`
+	// Shown under the entry, set by moderating it (see ApproveComment).
+	Approved bool `json:"approved"`
+}
+
+var headerPattern = regexp.MustCompile(`^[A-Za-z0-9-]+:`)
+
+// Longest comment accepted, in runes.
+const maxCommentLength = 5000
+
+// commentsOf reads the approved comments of the entry, oldest first.
+func (s *Site) commentsOf(e *Entry) ([]component.Comment, error) {
+	if s.Config.Comments.Dir == "" {
+		return nil, nil
+	}
+	files, err := filepath.Glob(filepath.Join(s.Config.Comments.Dir, e.Slug, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var cs []Comment
+	for _, f := range files {
+		c, err := readComment(f)
+		if err != nil {
+			return nil, err
+		}
+		if c.Approved {
+			cs = append(cs, c)
+		}
+	}
+	sort.SliceStable(cs, func(i, j int) bool {
+		return cs[i].Date.Before(cs[j].Date)
+	})
+	var comments []component.Comment
+	for _, c := range cs {
+		comment := component.Comment{Author: c.Author, URL: c.URL, Published: c.Date}
+		for _, p := range strings.Split(strings.ReplaceAll(c.Text, "\r\n", "\n"), "\n\n") {
+			if p = strings.TrimSpace(p); p != "" {
+				comment.Paragraphs = append(comment.Paragraphs, p)
+			}
+		}
+		comments = append(comments, comment)
+	}
+	return comments, nil
+}
+
+// commentForm is the form under the entry, if comments are accepted.
+func (s *Site) commentForm(e *Entry) *component.CommentForm {
+	if s.Config.Comments.Endpoint == "" || e.Data.Meta.Draft {
+		return nil
+	}
+	return &component.CommentForm{
+		Action: s.Config.Comments.Endpoint,
+		Entry: e.Slug,
+		Mail: strings.HasPrefix(s.Config.Comments.Endpoint, "mailto:"),
+	}
+}
+
+func readComment(p string) (Comment, error) {
+	var c Comment
+	bs, err := os.ReadFile(p)
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(bs, &c); err != nil {
+		return c, fmt.Errorf("%s: %w", p, err)
+	}
+	return c, nil
+}
+
+func writeComment(p string, c Comment) error {
+	bs, err := json.MarshalIndent(c, "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeCache(p, append(bs, '\n'))
+}
+
+// ImportComment turns a submission of the comment form into a comment file
+// waiting for approval, and returns its path. The submission is what the
+// endpoint stored (urlencoded or json), or the email sent by the form
+// (dated by its Date header). Submissions without a date are dated
+// received.
+// Call it after Load, the entry commented on has to exist.
+func (s *Site) ImportComment(submission []byte, received time.Time) (string, error) {
+	if s.Config.Comments.Dir == "" {
+		return "", fmt.Errorf("no comments directory configured")
+	}
+	fields, date, err := parseSubmission(submission)
+	if err != nil {
+		return "", err
+	}
+	if fields["subject"] != "" {
+		return "", fmt.Errorf("spam: the hidden field of the form is filled in")
+	}
+	slug := fields["entry"]
+	if !slices.ContainsFunc(s.Entries, func(e *Entry) bool { return e.Slug == slug }) {
+		return "", fmt.Errorf("comment on unknown entry: %q", slug)
+	}
+	c := Comment{
+		Author: strings.TrimSpace(fields["name"]),
+		Email: strings.TrimSpace(fields["email"]),
+		Text: strings.TrimSpace(fields["text"]),
+		Date: date,
+	}
+	if c.Date.IsZero() {
+		c.Date = received.Truncate(time.Second)
+	}
+	if u, err := url.Parse(strings.TrimSpace(fields["url"])); err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" {
+		c.URL = u.String()
+	}
+	switch {
+	case c.Author == "":
+		return "", fmt.Errorf("comment without a name")
+	case c.Text == "":
+		return "", fmt.Errorf("comment without text")
+	case utf8.RuneCountInString(c.Text) > maxCommentLength:
+		return "", fmt.Errorf("comment longer than %d characters", maxCommentLength)
+	}
+	sum := sha256.Sum256([]byte(c.Author + "\n" + c.Text))
+	p := filepath.Join(s.Config.Comments.Dir, slug, c.Date.UTC().Format("20060102-150405")+"-"+hex.EncodeToString(sum[:4])+".json")
+	if _, err := os.Stat(p); err == nil {
+		return p, fmt.Errorf("%s: already imported", p)
+	}
+	return p, writeComment(p, c)
+}
+
+// parseSubmission reads the fields of a submitted form, and when it was sent
+// if known.
+func parseSubmission(data []byte) (map[string]string, time.Time, error) {
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	var date time.Time
+	if !headerPattern.MatchString(text) {
+		// not an email, fields of the form can look like headers to
+		// net/mail
+	} else if msg, err := mail.ReadMessage(strings.NewReader(text)); err == nil {
+		date, _ = msg.Header.Date()
+		var body io.Reader = msg.Body
+		switch strings.ToLower(msg.Header.Get("Content-Transfer-Encoding")) {
+		case "quoted-printable":
+			body = quotedprintable.NewReader(body)
+		case "base64":
+			body = base64.NewDecoder(base64.StdEncoding, body)
+		}
+		bs, err := io.ReadAll(body)
+		if err != nil {
+			return nil, date, err
+		}
+		text = strings.ReplaceAll(string(bs), "\r\n", "\n")
+	}
+	text = strings.TrimSpace(text)
+	fields := map[string]string{}
+	switch {
+	case strings.HasPrefix(text, "{"):
+		if err := json.Unmarshal([]byte(text), &fields); err != nil {
+			return nil, date, err
+		}
+	case strings.Contains(text, "\n") || !strings.Contains(text, "&"):
+		// text/plain, as sent by mailto: forms: a name=value line per
+		// field, the text is the last one and spans the rest
+		lines := strings.Split(text, "\n")
+		for i, line := range lines {
+			k, v, ok := strings.Cut(line, "=")
+			if !ok {
+				continue
+			}
+			if k == "text" {
+				v = strings.Join(append([]string{v}, lines[i+1:]...), "\n")
+			}
+			fields[k] = v
+			if k == "text" {
+				break
+			}
+		}
+	default:
+		values, err := url.ParseQuery(text)
+		if err != nil {
+			return nil, date, err
+		}
+		for k := range values {
+			fields[k] = values.Get(k)
+		}
+	}
+	return fields, date, nil
+}
+
+// PendingComments lists the comment files waiting for approval.
+func (s *Site) PendingComments() ([]string, error) {
+	var pending []string
+	err := filepath.WalkDir(s.Config.Comments.Dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(p) != ".json" {
+			return err
+		}
+		c, err := readComment(p)
+		if err != nil {
+			return err
+		}
+		if !c.Approved {
+			pending = append(pending, p)
+		}
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return pending, err
+}
+
+// ApproveComment lets the comment in the file be shown. Rejecting a comment
+// is removing its file.
+func ApproveComment(p string) error {
+	c, err := readComment(p)
+	if err != nil {
+		return err
+	}
+	c.Approved = true
+	return writeComment(p, c)
+}
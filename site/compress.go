@@ -0,0 +1,76 @@
+package site
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os/exec"
+	"path"
+	"slices"
+)
+
+// Extensions of the files worth compressing.
+var compressible = []string{".html", ".css", ".js", ".json", ".xml", ".txt", ".svg", ".webmanifest"}
+
+// Smaller files don't compress well enough to be worth it.
+const minCompressSize = 256
+
+// precompress emits a .gz (and, if the brotli tool is installed, a .br)
+// sibling of every text file, so that the web server does not have to
+// compress them itself (e.g. nginx gzip_static / brotli_static).
+// Only files emitted since the last postprocess are compressed.
+func (s *Site) precompress() error {
+	for _, p := range s.pendingOutputs() {
+		data := s.outputs[p]
+		if !slices.Contains(compressible, path.Ext(p)) || len(data) < minCompressSize {
+			continue
+		}
+		gz, err := s.cached("gzip", func() ([]byte, error) {
+			return gzipBest(data)
+		}, data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+		if len(gz) < len(data) {
+			s.Emit(p+".gz", gz)
+		}
+		br, err := s.cached("brotli", func() ([]byte, error) {
+			return s.brotliBest(data)
+		}, data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+		if br != nil && len(br) < len(data) {
+			s.Emit(p+".br", br)
+		}
+	}
+	return nil
+}
+
+func gzipBest(data []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w, err := gzip.NewWriterLevel(buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	err = w.Close()
+	return buf.Bytes(), err
+}
+
+// brotliBest returns nil (and warns once) if brotli is not installed.
+func (s *Site) brotliBest(data []byte) ([]byte, error) {
+	if _, err := exec.LookPath("brotli"); err != nil {
+		s.warnOnce("brotli", "brotli not found, not precompressing files as .br")
+		return nil, nil
+	}
+	out, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd := exec.Command("brotli", "--best", "--stdout")
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = bytes.NewReader(data), out, stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("brotli: %w: %s", err, stderr.Bytes())
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,357 @@
+package site
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"be/component"
+)
+
+type Config struct {
+	BlogName string `json:"blog_name"`
+	Description string `json:"description"`
+	// Used where an entry does not specify its own.
+	Author component.Author `json:"author"`
+	Language string `json:"language"`
+	// Absolute URL the site is served from, e.g. https://blog.vanloo.ch
+	BaseURL string `json:"base_url"`
+	// Directory containing the *.be entry sources.
+	Sources string `json:"sources"`
+	// Directory containing static files, copied to /public/.
+	Public string `json:"public"`
+	// Directory the generated site is written to.
+	Output string `json:"output"`
+	// Url of the entries, relative to the site, with the placeholders
+	// :slug (name of the source file), :lang (of the entry) and :year,
+	// :month and :day (of publishing), e.g. /:year/:month/:slug/ or
+	// /posts/:slug.html. Urls ending in a slash (or without an extension)
+	// are directories with an index.html.
+	// Changing it moves all entries, (alias) them to keep the old urls
+	// working.
+	Permalink string `json:"permalink"`
+	// Directory with templates (<name>.be) of new entries, see blog new.
+	Archetypes string `json:"archetypes"`
+	// Source of the page served for missing urls, rendered to 404.html.
+	NotFound string `json:"not_found"`
+	// Generate a preview image for entries that have none.
+	PreviewImages bool `json:"preview_images"`
+	// Optional background image (1200x630) the previews are drawn onto.
+	PreviewTemplate string `json:"preview_template"`
+	// Ask search engines not to index archived or expired entries.
+	NoIndexOutdated bool `json:"noindex_outdated"`
+	// Widths (in pixels) images are scaled to, by name.
+	ImageSizes map[string]int `json:"image_sizes"`
+	// Widths offered to the browser (as srcset) by kind of image.
+	ImageKinds map[string]ImageKind `json:"image_kinds"`
+	// Additional formats images are converted to ("webp", "avif"), the
+	// original format is kept as fallback. None by default.
+	// Requires the cwebp and avifenc tools respectively (on the PATH), the
+	// build fails without them. Dev builds only warn, and leave the format
+	// out.
+	ImageFormats []string `json:"image_formats"`
+	// Directory for intermediate build artifacts, kept between builds.
+	Cache string `json:"cache"`
+	// How entry aliases are emitted, one of RedirectMeta, RedirectNetlify
+	// or RedirectHtaccess.
+	Redirects string `json:"redirects"`
+	// Commands run before and after building and deploying.
+	Hooks Hooks `json:"hooks"`
+	// Security headers for the web server to send.
+	Headers Headers `json:"headers"`
+	// Contacts for security issues published in /.well-known/security.txt,
+	// nothing is if it has none.
+	SecurityTxt SecurityTxt `json:"security_txt"`
+	// Files published in /.well-known/, by their path in it, e.g.
+	// {"matrix/server": "well-known/matrix-server.json"}.
+	WellKnown map[string]string `json:"well_known"`
+	// Stylesheets and scripts (relative to the public directory) that are
+	// concatenated and minified into a single file, by name of the bundle.
+	// Bundles are written to the top of the public directory, relative urls
+	// in the sources only keep working if they are there as well.
+	Bundles map[string][]string `json:"bundles"`
+	// Square image (at least 512x512) the favicons, apple-touch-icon and web
+	// manifest icons are generated from.
+	Favicon string `json:"favicon"`
+	// Color of the browser ui around the site, e.g. #d0d0d0
+	ThemeColor string `json:"theme_color"`
+	Themes Themes `json:"themes"`
+	// Directory with templates replacing those of the default theme (see
+	// component.LoadTheme), the look of the pages.
+	Theme string `json:"theme"`
+	// Values for the templates of the theme, as Site.Params.
+	Params map[string]any `json:"params"`
+	// Put the hash of their content into the names of stylesheets and
+	// scripts, so they can be served with far-future cache headers.
+	// Not done in dev builds.
+	Fingerprint bool `json:"fingerprint"`
+	// Emit gzip and brotli compressed copies of all text files, brotli
+	// requires the brotli tool. Not done in dev builds.
+	Precompress bool `json:"precompress"`
+	// Development build: the sources of the bundles are published and linked
+	// as they are, and the html pages are not minified.
+	Dev bool `json:"dev"`
+	// Minify the html pages (unless Dev), bundles and theme.
+	Minify bool `json:"minify"`
+	// Include entries marked as (draft).
+	Drafts bool `json:"drafts"`
+	// Include entries published in the future.
+	Future bool `json:"future"`
+	// Html included in the head of every page, e.g. the script of an
+	// analytics service.
+	Analytics string `json:"analytics"`
+	// Profiles of the author on other sites, e.g.
+	// ["https://github.com/cvanloo", "https://mastodon.social/@cvl", "mailto:colin@vanloo.ch"].
+	// Every page links them as rel=me, which Mastodon checks to show the
+	// link to the site as verified, and IndieAuth to log in with them.
+	Identities []string `json:"identities"`
+	// Server that lets the site be used as identity to log in with, see
+	// https://indieauth.spec.indieweb.org/
+	IndieAuth IndieAuth `json:"indieauth"`
+	// Reactions from other sites shown under the entries.
+	Webmentions Webmentions `json:"webmentions"`
+	// Account the blog can be followed as from Mastodon and co.
+	ActivityPub ActivityPub `json:"activitypub"`
+	// Comments of readers, kept in the repository.
+	Comments Comments `json:"comments"`
+	// Accounts new entries are announced from after deploying them.
+	Mastodon Mastodon `json:"mastodon"`
+	Bluesky Bluesky `json:"bluesky"`
+	// File remembering which entries were announced where, keep it (e.g.
+	// commit it) so that none is announced twice. Entries published before
+	// the first announcement on a service are never announced on it.
+	Announced string `json:"announced"`
+	// Digests of the new entries, see blog newsletter.
+	Newsletter Newsletter `json:"newsletter"`
+	// Dictionaries and the words of the site, see blog spell.
+	Spell Spell `json:"spell"`
+	// Where blog deploy uploads the output directory to, by name.
+	Deploy map[string]DeployTarget `json:"deploy"`
+	// Name of the profile used unless another one is asked for.
+	Profile string `json:"profile"`
+	// Named sets of options overriding the ones above, e.g. a "dev" profile
+	// for local previews that includes drafts and sets a local base_url.
+	// Same format as the config itself.
+	Profiles map[string]json.RawMessage `json:"profiles"`
+}
+
+type IndieAuth struct {
+	// e.g. https://indieauth.com/auth and https://tokens.indieauth.com/token
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint string `json:"token_endpoint"`
+	// Url of the metadata of the server, which newer clients look for
+	// instead of the endpoints.
+	Metadata string `json:"metadata"`
+}
+
+// Themes are the color schemes of the site, see buildTheme.
+type Themes struct {
+	Light Theme `json:"light"`
+	Dark Theme `json:"dark"`
+	// Let readers switch to the other scheme.
+	Toggle bool `json:"toggle"`
+}
+
+type Theme struct {
+	// File with the custom properties (--color-bg: ...;) of the scheme.
+	Palette string `json:"palette"`
+	// Chroma style code blocks are highlighted with.
+	// @from: https://xyproto.github.io/splash/docs/
+	Syntax string `json:"syntax"`
+}
+
+type ImageKind struct {
+	Widths []int `json:"widths"`
+	// Value of the sizes attribute, i.e. how wide the image is displayed.
+	Sizes string `json:"sizes"`
+}
+
+const (
+	// A stub page per alias, redirecting via <meta http-equiv="refresh">.
+	RedirectMeta = "meta"
+	// A single _redirects file, as understood by Netlify, Cloudflare Pages, ...
+	RedirectNetlify = "_redirects"
+	// A single .htaccess file for Apache.
+	RedirectHtaccess = "htaccess"
+)
+
+const defaultPermalink = "/:slug.html"
+
+func DefaultConfig() Config {
+	return Config{
+		BlogName: "save-lisp-and-die",
+		Description: "A blog about programming weird computers using weird languages.",
+		Author: component.Author{Name: "cvl"},
+		Language: "en",
+		BaseURL: "https://blog.vanloo.ch",
+		Sources: "entries",
+		Public: "public",
+		Output: "build",
+		NotFound: "pages/404.be",
+		Archetypes: "archetypes",
+		Permalink: defaultPermalink,
+		PreviewImages: true,
+		ImageSizes: map[string]int{
+			"thumb": 320,
+			"medium": 800,
+			"full": 1600,
+		},
+		ImageKinds: map[string]ImageKind{
+			"inline": {Widths: []int{320, 800, 1600}, Sizes: "(min-width: 60ch) 60ch, 100vw"},
+			"gallery": {Widths: []int{320, 640}, Sizes: "(min-width: 60ch) 20ch, 33vw"},
+			"hero": {Widths: []int{800, 1600, 2400}, Sizes: "100vw"},
+		},
+		Cache: ".cache",
+		Redirects: RedirectMeta,
+		Headers: Headers{ReferrerPolicy: "strict-origin-when-cross-origin"},
+		Bundles: map[string][]string{
+			"bundle.css": {"styles.css"},
+		},
+		Favicon: "favicon.png",
+		ThemeColor: "#d0d0d0",
+		Themes: Themes{
+			Light: Theme{Palette: "themes/light.css", Syntax: "xcode"},
+			Dark: Theme{Palette: "themes/dark.css", Syntax: "xcode-dark"},
+			Toggle: true,
+		},
+		Fingerprint: true,
+		Precompress: true,
+		Minify: true,
+		Webmentions: Webmentions{MaxAge: "1h"},
+		Comments: Comments{Dir: "comments"},
+		Mastodon: Mastodon{
+			Template: defaultAnnouncement,
+			Visibility: "public",
+		},
+		Bluesky: Bluesky{Template: defaultAnnouncement},
+		Announced: "announced.json",
+		Newsletter: Newsletter{
+			Subject: defaultNewsletterSubject,
+			State: "newsletter.json",
+		},
+		Spell: Spell{
+			Dictionaries: map[string]string{"en": "en_US", "de": "de_CH"},
+			Words: "words.txt",
+		},
+		Profiles: map[string]json.RawMessage{
+			"dev": json.RawMessage(`{"dev": true, "drafts": true, "future": true, "base_url": "http://localhost:8080", "analytics": ""}`),
+			"prod": json.RawMessage(`{}`),
+		},
+	}
+}
+
+// LoadConfig reads the json config at path on top of the defaults, and
+// applies the named profile on top of that (the one of the config if
+// profile is empty), and finally the BLOG_ environment variables (see
+// applyEnv).
+// A missing config file is not an error.
+func LoadConfig(path, profile string) (Config, error) {
+	cfg := DefaultConfig()
+	bs, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return cfg, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(bs, &cfg); err != nil {
+			return cfg, err
+		}
+	}
+	if profile == "" {
+		profile = os.Getenv("BLOG_PROFILE")
+	}
+	if profile == "" {
+		profile = cfg.Profile
+	}
+	if profile != "" {
+		overrides, ok := cfg.Profiles[profile]
+		if !ok {
+			return cfg, fmt.Errorf("unknown profile: %s", profile)
+		}
+		cfg.Profile = profile
+		if err := json.Unmarshal(overrides, &cfg); err != nil {
+			return cfg, err
+		}
+	}
+	return cfg, applyEnv(&cfg, os.Environ())
+}
+
+// applyEnv overrides the options of the config with the environment
+// variables named after them: BLOG_ and the json key in upper case. Keys of
+// nested objects are separated by two underscores, e.g.
+//
+//	BLOG_BASE_URL=http://localhost:8080
+//	BLOG_MINIFY=false
+//	BLOG_DEPLOY__PROD__SECRET_KEY=...
+//
+// Values of options that are not strings are json ("false", "[320, 800]").
+// BLOG_PROFILE selects the profile instead (see LoadConfig), variables not
+// naming an option are ignored. Nesting below an option that is not an
+// object (BLOG_BLOG_NAME__X) is an error.
+func applyEnv(cfg *Config, environ []string) error {
+	var overrides [][2]string
+	for _, kv := range environ {
+		k, v, _ := strings.Cut(kv, "=")
+		if name, ok := strings.CutPrefix(k, "BLOG_"); ok && name != "PROFILE" && name != "" {
+			overrides = append(overrides, [2]string{name, v})
+		}
+	}
+	if len(overrides) == 0 {
+		return nil
+	}
+	bs, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	tree := map[string]any{}
+	if err := json.Unmarshal(bs, &tree); err != nil {
+		return err
+	}
+	for _, o := range overrides {
+		keys := strings.Split(strings.ToLower(o[0]), "__")
+		obj := tree
+		for i, k := range keys[:len(keys)-1] {
+			k = jsonKey(obj, k)
+			next, ok := obj[k].(map[string]any)
+			if !ok {
+				if obj[k] != nil {
+					return fmt.Errorf("BLOG_%s: %s is not an object", o[0], strings.ToUpper(strings.Join(keys[:i+1], "__")))
+				}
+				next = map[string]any{}
+				obj[k] = next
+			}
+			obj = next
+		}
+		k := jsonKey(obj, keys[len(keys)-1])
+		if _, ok := obj[k].(string); ok {
+			obj[k] = o[1]
+			continue
+		}
+		var v any
+		if err := json.Unmarshal([]byte(o[1]), &v); err != nil {
+			if obj[k] != nil {
+				return fmt.Errorf("BLOG_%s: %w", o[0], err)
+			}
+			v = o[1] // not set (or null) so far, of unknown type
+		}
+		obj[k] = v
+	}
+	if bs, err = json.Marshal(tree); err != nil {
+		return err
+	}
+	*cfg = Config{}
+	return json.Unmarshal(bs, cfg)
+}
+
+// jsonKey finds the key of obj that is k, ignoring case (the keys of the
+// author for example are not lowercase), or returns k if there is none.
+func jsonKey(obj map[string]any, k string) string {
+	for key := range obj {
+		if strings.EqualFold(key, k) {
+			return key
+		}
+	}
+	return k
+}
@@ -0,0 +1,36 @@
+package site
+
+import "testing"
+
+func TestApplyEnv(t *testing.T) {
+	cfg := DefaultConfig()
+	err := applyEnv(&cfg, []string{
+		"BLOG_BASE_URL=http://localhost:8080",
+		"BLOG_MINIFY=false",
+		"BLOG_IMAGE_SIZES__THUMB=100",
+		"BLOG_HEADERS__EXTRA__X_TEST=on",
+		"BLOG_UNKNOWN__OPTION=1",
+		"HOME=/root",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.BaseURL != "http://localhost:8080" || cfg.Minify || cfg.ImageSizes["thumb"] != 100 || cfg.ImageSizes["full"] != 1600 {
+		t.Errorf("not overridden: %+v", cfg)
+	}
+	if cfg.Headers.Extra["x_test"] != "on" {
+		t.Errorf("extra headers %v", cfg.Headers.Extra)
+	}
+
+	for _, tt := range []struct{ env, err string }{
+		{"BLOG_BLOG_NAME__X=1", "BLOG_BLOG_NAME__X: BLOG_NAME is not an object"},
+		{"BLOG_AUTHOR__NAME__X=1", "BLOG_AUTHOR__NAME__X: AUTHOR__NAME is not an object"},
+		{"BLOG_BUNDLES__BUNDLE.CSS__X=1", "BLOG_BUNDLES__BUNDLE.CSS__X: BUNDLES__BUNDLE.CSS is not an object"},
+		{"BLOG_MINIFY=yes", "BLOG_MINIFY: invalid character 'y' looking for beginning of value"},
+	} {
+		cfg := DefaultConfig()
+		if err := applyEnv(&cfg, []string{tt.env}); err == nil || err.Error() != tt.err {
+			t.Errorf("%s: error %v, want %s", tt.env, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package site
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+type DeployTarget struct {
+	// One of DeployRsync, DeploySFTP or DeployS3.
+	Kind string `json:"kind"`
+	// Where to upload to:
+	//   - rsync and sftp: [user@]host:directory, the ssh config applies
+	//   - s3: name of the bucket
+	Destination string `json:"destination"`
+	// s3 only: url of the service (e.g. https://s3.eu-central-1.amazonaws.com)
+	// and its region.
+	Endpoint string `json:"endpoint"`
+	Region string `json:"region"`
+	// s3 only: credentials, best not committed but set from the environment
+	// (BLOG_DEPLOY__<TARGET>__SECRET_KEY). AWS_ACCESS_KEY_ID and
+	// AWS_SECRET_ACCESS_KEY are used if empty.
+	AccessKey string `json:"access_key"`
+	SecretKey string `json:"secret_key"`
+	// Delete the remote files no longer in the output directory (rsync
+	// deletes all remote files not in it, not only those deployed before).
+	Prune bool `json:"prune"`
+	// Command run after uploading, to invalidate the urls in a CDN. The
+	// argument {paths} is replaced by the paths to invalidate, e.g.
+	// ["aws", "cloudfront", "create-invalidation", "--distribution-id", "...", "--paths", "{paths}"]
+	Invalidate []string `json:"invalidate"`
+	// Paths to invalidate (e.g. ["/*"]), instead of those of the uploaded and
+	// deleted files.
+	InvalidatePaths []string `json:"invalidate_paths"`
+}
+
+const (
+	DeployRsync = "rsync"
+	DeploySFTP = "sftp"
+	DeployS3 = "s3"
+)
+
+// Deployment is what deploying the output directory to a target (again)
+// changes.
+type Deployment struct {
+	Target string
+	// Slash separated, relative to the output directory.
+	Upload, Delete []string
+	// content hashes of all files in the output directory
+	hashes map[string]string
+}
+
+// PlanDeploy compares the output to what was deployed to the target the
+// last time, only changed files are uploaded again: the outputs of the
+// build if the site was built (there is no need to Write them first to
+// plan), the output directory as it is otherwise.
+// What was deployed is remembered in the cache directory, a target deployed
+// to from elsewhere (or with the cache cleaned) is uploaded to in full.
+func (s *Site) PlanDeploy(target string) (*Deployment, error) {
+	if _, ok := s.Config.Deploy[target]; !ok {
+		return nil, fmt.Errorf("unknown deploy target: %s", target)
+	}
+	deployed := s.loadDeployed(target)
+	hashes, err := s.outputHashes()
+	if err != nil {
+		return nil, err
+	}
+	d := &Deployment{Target: target, hashes: hashes}
+	for p, h := range hashes {
+		if deployed[p] != h {
+			d.Upload = append(d.Upload, p)
+		}
+	}
+	if s.Config.Deploy[target].Prune {
+		for p := range deployed {
+			if _, ok := d.hashes[p]; !ok {
+				d.Delete = append(d.Delete, p)
+			}
+		}
+	}
+	sort.Strings(d.Upload)
+	sort.Strings(d.Delete)
+	return d, nil
+}
+
+// outputHashes are the content hashes of the outputs by their path, of those
+// emitted if the site was built, or else of the files in the output
+// directory.
+func (s *Site) outputHashes() (map[string]string, error) {
+	hashes := map[string]string{}
+	hash := func(p string, bs []byte) {
+		sum := sha256.Sum256(bs)
+		hashes[p] = hex.EncodeToString(sum[:])
+	}
+	s.mu.Lock()
+	for p, bs := range s.outputs {
+		hash(p, bs)
+	}
+	s.mu.Unlock()
+	if len(hashes) > 0 {
+		return hashes, nil
+	}
+	// the current release, if published by the Publisher
+	root, err := filepath.EvalSymlinks(s.Config.Output)
+	if err != nil {
+		return nil, err
+	}
+	err = filepath.WalkDir(root, func(p string, de fs.DirEntry, err error) error {
+		if err != nil || de.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		bs, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		hash(filepath.ToSlash(rel), bs)
+		return nil
+	})
+	return hashes, err
+}
+
+// Deploy uploads and deletes the files of the deployment, then invalidates
+// them in the CDN if the target is configured to.
+func (s *Site) Deploy(d *Deployment) error {
+	t := s.Config.Deploy[d.Target]
+	if len(d.Upload) == 0 && len(d.Delete) == 0 {
+		return nil
+	}
+	var err error
+	switch t.Kind {
+	case DeployRsync:
+		err = s.deployRsync(t, d)
+	case DeploySFTP:
+		err = s.deploySFTP(t, d)
+	case DeployS3:
+		err = s.deployS3(t, d)
+	default:
+		err = fmt.Errorf("invalid deploy kind: %s", t.Kind)
+	}
+	if err != nil {
+		return fmt.Errorf("deploy %s: %w", d.Target, err)
+	}
+	if err := s.saveDeployed(d.Target, d.hashes); err != nil {
+		return err
+	}
+	return invalidate(t, d)
+}
+
+func (s *Site) deployRsync(t DeployTarget, d *Deployment) error {
+	src := filepath.Clean(s.Config.Output) + "/"
+	dst := strings.TrimSuffix(t.Destination, "/") + "/"
+	if len(d.Upload) > 0 {
+		cmd := exec.Command("rsync", "--archive", "--compress", "--files-from=-", src, dst)
+		cmd.Stdin = strings.NewReader(strings.Join(d.Upload, "\n") + "\n")
+		if err := run(cmd); err != nil {
+			return err
+		}
+	}
+	if len(d.Delete) > 0 {
+		// transfers nothing, only deletes what is no longer there locally
+		cmd := exec.Command("rsync", "--recursive", "--delete", "--existing", "--ignore-existing", src, dst)
+		if err := run(cmd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Site) deploySFTP(t DeployTarget, d *Deployment) error {
+	host, dir, ok := strings.Cut(t.Destination, ":")
+	if !ok {
+		return fmt.Errorf("sftp destination must be [user@]host:directory: %s", t.Destination)
+	}
+	made := map[string]bool{}
+	for _, p := range d.Upload {
+		for parent := path.Dir(p); parent != "." && !made[parent]; parent = path.Dir(parent) {
+			made[parent] = true
+		}
+	}
+	subdirs := make([]string, 0, len(made))
+	for sub := range made {
+		subdirs = append(subdirs, sub)
+	}
+	sort.Strings(subdirs) // parents first
+	batch := &strings.Builder{}
+	for _, sub := range subdirs {
+		// the leading - lets sftp carry on if the directory already exists
+		fmt.Fprintf(batch, "-mkdir %s\n", sftpQuote(path.Join(dir, sub)))
+	}
+	for _, p := range d.Upload {
+		fmt.Fprintf(batch, "put %s %s\n", sftpQuote(filepath.Join(s.Config.Output, filepath.FromSlash(p))), sftpQuote(path.Join(dir, p)))
+	}
+	for _, p := range d.Delete {
+		fmt.Fprintf(batch, "-rm %s\n", sftpQuote(path.Join(dir, p)))
+	}
+	cmd := exec.Command("sftp", "-b", "-", host)
+	cmd.Stdin = strings.NewReader(batch.String())
+	return run(cmd)
+}
+
+func sftpQuote(p string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(p) + `"`
+}
+
+func (s *Site) deployS3(t DeployTarget, d *Deployment) error {
+	c := &s3Client{
+		endpoint: t.Endpoint,
+		bucket: t.Destination,
+		region: t.Region,
+		accessKey: cmp.Or(t.AccessKey, os.Getenv("AWS_ACCESS_KEY_ID")),
+		secretKey: cmp.Or(t.SecretKey, os.Getenv("AWS_SECRET_ACCESS_KEY")),
+		http: http.DefaultClient,
+	}
+	if c.endpoint == "" || c.region == "" {
+		return fmt.Errorf("s3 targets need an endpoint and a region")
+	}
+	if c.accessKey == "" || c.secretKey == "" {
+		return fmt.Errorf("s3 targets need credentials, set access_key and secret_key (or AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY)")
+	}
+	for _, p := range d.Upload {
+		bs, err := os.ReadFile(filepath.Join(s.Config.Output, filepath.FromSlash(p)))
+		if err != nil {
+			return err
+		}
+		if err := c.put(p, bs); err != nil {
+			return err
+		}
+		slog.Debug("uploaded", "path", p)
+	}
+	for _, p := range d.Delete {
+		if err := c.delete(p); err != nil {
+			return err
+		}
+		slog.Debug("deleted", "path", p)
+	}
+	return nil
+}
+
+func invalidate(t DeployTarget, d *Deployment) error {
+	if len(t.Invalidate) == 0 {
+		return nil
+	}
+	paths := t.InvalidatePaths
+	if len(paths) == 0 {
+		seen := map[string]bool{}
+		for _, p := range slices.Concat(d.Upload, d.Delete) {
+			// the page may be cached under its canonical url as well
+			for _, u := range []string{"/" + p, Canonical(p)} {
+				if !seen[u] {
+					seen[u] = true
+					paths = append(paths, u)
+				}
+			}
+		}
+	}
+	var args []string
+	for _, a := range t.Invalidate[1:] {
+		if a == "{paths}" {
+			args = append(args, paths...)
+		} else {
+			args = append(args, a)
+		}
+	}
+	return run(exec.Command(t.Invalidate[0], args...))
+}
+
+func run(cmd *exec.Cmd) error {
+	out := &bytes.Buffer{}
+	cmd.Stdout, cmd.Stderr = out, out
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %w: %s", cmd.Args[0], err, bytes.TrimSpace(out.Bytes()))
+	}
+	return nil
+}
+
+func (s *Site) deployedPath(target string) string {
+	return filepath.Join(s.Config.Cache, "deployed", target+".json")
+}
+
+// loadDeployed maps the paths deployed to the target to the hash of their
+// content.
+func (s *Site) loadDeployed(target string) map[string]string {
+	deployed := map[string]string{}
+	if bs, err := os.ReadFile(s.deployedPath(target)); err == nil {
+		json.Unmarshal(bs, &deployed) // upload everything if corrupted
+	}
+	return deployed
+}
+
+func (s *Site) saveDeployed(target string, deployed map[string]string) error {
+	bs, err := json.Marshal(deployed)
+	if err != nil {
+		return err
+	}
+	return writeCache(s.deployedPath(target), bs)
+}
@@ -0,0 +1,58 @@
+package site
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestPlanDeploy(t *testing.T) {
+	dir := t.TempDir()
+	cfg := Config{
+		Output: filepath.Join(dir, "build"),
+		Cache: filepath.Join(dir, "cache"),
+		Deploy: map[string]DeployTarget{"web": {Kind: DeployRsync, Prune: true}},
+	}
+	s := New(cfg)
+	s.outputs = map[string][]byte{"index.html": []byte("index"), "a.html": []byte("a"), "b.html": []byte("b")}
+	d, err := s.PlanDeploy("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a.html", "b.html", "index.html"}; !slices.Equal(d.Upload, want) || len(d.Delete) > 0 {
+		t.Fatalf("first deploy: upload %v, delete %v; want upload %v", d.Upload, d.Delete, want)
+	}
+	if err := s.saveDeployed("web", d.hashes); err != nil {
+		t.Fatal(err)
+	}
+
+	s.outputs = map[string][]byte{"index.html": []byte("index, changed"), "a.html": []byte("a"), "c.html": []byte("c")}
+	d, err = s.PlanDeploy("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"c.html", "index.html"}; !slices.Equal(d.Upload, want) {
+		t.Errorf("upload %v, want %v", d.Upload, want)
+	}
+	if want := []string{"b.html"}; !slices.Equal(d.Delete, want) {
+		t.Errorf("delete %v, want %v", d.Delete, want)
+	}
+
+	// not built, the output directory is compared
+	if err := os.MkdirAll(cfg.Output, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"index.html", "a.html", "b.html"} {
+		if err := os.WriteFile(filepath.Join(cfg.Output, name), []byte(name[:len(name)-len(".html")]), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d, err = New(cfg).PlanDeploy("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Upload) > 0 || len(d.Delete) > 0 {
+		t.Errorf("unchanged output directory: upload %v, delete %v", d.Upload, d.Delete)
+	}
+}
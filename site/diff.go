@@ -0,0 +1,273 @@
+package site
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type (
+	ChangeKind rune
+
+	// Change is the difference of an emitted file to the one in the output
+	// directory.
+	Change struct {
+		Kind ChangeKind
+		// Slash separated, relative to the output directory.
+		Path string
+		// Content in the output directory, and as emitted.
+		Old, New []byte
+	}
+)
+
+const (
+	Created ChangeKind = '+'
+	Changed ChangeKind = '~'
+	// No longer emitted, but still in the output directory.
+	Deleted ChangeKind = '-'
+)
+
+// Diff compares the emitted files to the output directory, and reports what
+// writing them would change, sorted by path.
+func (s *Site) Diff() ([]Change, error) {
+	var changes []Change
+	for _, p := range s.Outputs() {
+		old, err := os.ReadFile(filepath.Join(s.Config.Output, filepath.FromSlash(p)))
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			changes = append(changes, Change{Kind: Created, Path: p, New: s.outputs[p]})
+		case err != nil:
+			return nil, err
+		case !bytes.Equal(old, s.outputs[p]):
+			changes = append(changes, Change{Kind: Changed, Path: p, Old: old, New: s.outputs[p]})
+		}
+	}
+	orphans, err := s.Orphans()
+	for _, p := range orphans {
+		old, err := os.ReadFile(filepath.Join(s.Config.Output, filepath.FromSlash(p)))
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, Change{Kind: Deleted, Path: p, Old: old})
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, err
+}
+
+// Orphans lists the files in the output directory (slash separated,
+// relative to it) that are no longer emitted, e.g. the pages of renamed
+// entries.
+// An output directory that is a symlink (to the current release, see
+// Publisher) is followed, the symlink itself is never an orphan.
+func (s *Site) Orphans() ([]string, error) {
+	root, err := filepath.EvalSymlinks(s.Config.Output)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil // never built
+	} else if err != nil {
+		return nil, err
+	}
+	var orphans []string
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		s.mu.Lock()
+		_, ok := s.outputs[filepath.ToSlash(rel)]
+		s.mu.Unlock()
+		if !ok {
+			orphans = append(orphans, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	return orphans, err
+}
+
+// RemoveOrphans removes the orphans (see Orphans) from the output directory,
+// along with the directories left empty, and reports the removed files.
+func (s *Site) RemoveOrphans() ([]string, error) {
+	orphans, err := s.Orphans()
+	if err != nil {
+		return nil, err
+	}
+	dirs := map[string]bool{}
+	for _, p := range orphans {
+		dst := filepath.Join(s.Config.Output, filepath.FromSlash(p))
+		if err := os.Remove(dst); err != nil {
+			return nil, err
+		}
+		for dir := filepath.Dir(dst); dir != filepath.Clean(s.Config.Output) && dir != "."; dir = filepath.Dir(dir) {
+			dirs[dir] = true
+		}
+	}
+	// deepest first, so that parents are empty by the time they are tried
+	sorted := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		sorted = append(sorted, dir)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return len(sorted[i]) > len(sorted[j])
+	})
+	for _, dir := range sorted {
+		if entries, err := os.ReadDir(dir); err == nil && len(entries) == 0 {
+			if err := os.Remove(dir); err != nil {
+				return orphans, err
+			}
+		}
+	}
+	return orphans, nil
+}
+
+// Limit on the product of the line counts of the two versions, above which
+// no diff is computed.
+const maxDiffSize = 4_000_000
+
+// WriteDiff writes the difference between the old and new content as a
+// unified diff with 3 lines of context.
+// Html is split after every tag as well, so that minified pages (with
+// everything on one line) still diff sensibly.
+func (c Change) WriteDiff(w io.Writer) error {
+	a, b := diffLines(c.Old), diffLines(c.New)
+	if len(a)*len(b) > maxDiffSize {
+		_, err := fmt.Fprintf(w, "(%s is too large to diff)\n", c.Path)
+		return err
+	}
+	aName, bName := "a/"+c.Path, "b/"+c.Path
+	if c.Kind == Created {
+		aName = "/dev/null"
+	} else if c.Kind == Deleted {
+		bName = "/dev/null"
+	}
+	if _, err := fmt.Fprintf(w, "--- %s\n+++ %s\n", aName, bName); err != nil {
+		return err
+	}
+	for _, h := range hunks(editScript(a, b), 3) {
+		if _, err := fmt.Fprint(w, h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func diffLines(data []byte) []string {
+	var lines []string
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		for line != "" {
+			end := strings.IndexByte(line, '>') + 1
+			if end == 0 || end == len(line) || (end == len(line)-1 && line[end] == '\n') {
+				end = len(line)
+			}
+			lines = append(lines, line[:end])
+			line = line[end:]
+		}
+	}
+	return lines
+}
+
+type edit struct {
+	// ' ' kept, '-' removed, '+' added
+	op byte
+	line string
+	// line numbers (counting from 1) in the old and new version
+	a, b int
+}
+
+// editScript turns a into b, using the longest common subsequence of their
+// lines.
+func editScript(a, b []string) []edit {
+	// lcs[i][j] is the length of the lcs of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var script []edit
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			script = append(script, edit{' ', a[i], i + 1, j + 1})
+			i, j = i+1, j+1
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			script = append(script, edit{'-', a[i], i + 1, j})
+			i++
+		default:
+			script = append(script, edit{'+', b[j], i, j + 1})
+			j++
+		}
+	}
+	return script
+}
+
+// hunks groups the changes of the script along with context lines around
+// them.
+func hunks(script []edit, context int) []string {
+	var hs []string
+	for start := 0; start < len(script); {
+		for start < len(script) && script[start].op == ' ' {
+			start++
+		}
+		if start == len(script) {
+			break
+		}
+		from := max(0, start-context)
+		// extend until more than 2*context kept lines follow the last change
+		end, kept := start, 0
+		for i := start; i < len(script) && kept <= 2*context; i++ {
+			if script[i].op == ' ' {
+				kept++
+			} else {
+				end, kept = i+1, 0
+			}
+		}
+		to := min(len(script), end+context)
+
+		sb := &strings.Builder{}
+		aStart, bStart, aLen, bLen := 0, 0, 0, 0
+		for _, e := range script[from:to] {
+			if e.op != '+' {
+				if aLen == 0 {
+					aStart = e.a
+				}
+				aLen++
+			}
+			if e.op != '-' {
+				if bLen == 0 {
+					bStart = e.b
+				}
+				bLen++
+			}
+			sb.WriteByte(e.op)
+			sb.WriteString(strings.TrimSuffix(e.line, "\n"))
+			sb.WriteByte('\n')
+		}
+		if aLen == 0 {
+			aStart = script[from].a
+		}
+		if bLen == 0 {
+			bStart = script[from].b
+		}
+		hs = append(hs, fmt.Sprintf("@@ -%d,%d +%d,%d @@\n", aStart, aLen, bStart, bLen)+sb.String())
+		start = to
+	}
+	return hs
+}
@@ -0,0 +1,506 @@
+package site
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"sort"
+)
+
+// Published images carry no metadata (location, device serial numbers, ...),
+// the copies of the public directory keep just their orientation. An entry
+// can explicitly ask to keep some of it:
+//   (exif camera exposure)
+// keeps the camera and exposure settings in the jpeg variants of its images.
+
+type exifEntry struct {
+	Tag, Type uint16
+	Count uint32
+	// raw value, in the byte order of the file it was read from
+	Value []byte
+}
+
+const (
+	exifIFDPointer = 0x8769
+	exifOrientation = 0x0112
+)
+
+// Tags kept per group, by the IFD they live in.
+var exifGroups = map[string]struct{ IFD0, Exif []uint16 }{
+	"camera": {
+		IFD0: []uint16{0x010F /* Make */, 0x0110 /* Model */},
+		Exif: []uint16{0xA433 /* LensMake */, 0xA434 /* LensModel */},
+	},
+	"exposure": {
+		Exif: []uint16{
+			0x829A, // ExposureTime
+			0x829D, // FNumber
+			0x8822, // ExposureProgram
+			0x8827, // ISOSpeedRatings
+			0x9204, // ExposureBiasValue
+			0x920A, // FocalLength
+		},
+	},
+	"date": {
+		Exif: []uint16{0x9003 /* DateTimeOriginal */},
+	},
+}
+
+var exifTypeSize = map[uint16]uint32{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 7: 1, 9: 4, 10: 8}
+
+// jpegSegments calls fn for every marker segment before the image data.
+// Returning false from fn drops the segment. The remaining file is returned.
+func jpegSegments(data []byte, fn func(marker byte, payload []byte) bool) []byte {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return data
+	}
+	out := &bytes.Buffer{}
+	out.Write(data[:2])
+	i := 2
+	for i+4 <= len(data) && data[i] == 0xFF {
+		marker := data[i+1]
+		if marker == 0xDA { // start of scan, image data follows
+			break
+		}
+		l := int(binary.BigEndian.Uint16(data[i+2:]))
+		if i+2+l > len(data) {
+			break
+		}
+		if fn(marker, data[i+4:i+2+l]) {
+			out.Write(data[i : i+2+l])
+		}
+		i += 2 + l
+	}
+	out.Write(data[i:])
+	return out.Bytes()
+}
+
+// stripMetadata removes exif, xmp, comments, etc. from jpeg, png, webp and
+// gif files, without re-encoding them. Color profiles are kept, and so is
+// the exif orientation (the only tag left), for photos to be shown upright.
+func stripMetadata(data []byte) []byte {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8}):
+		return stripJPEG(data)
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return stripPNG(data)
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return stripWebP(data)
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		return stripGIF(data)
+	}
+	return data
+}
+
+func stripJPEG(data []byte) []byte {
+	var orientation []byte
+	stripped := jpegSegments(data, func(marker byte, payload []byte) bool {
+		switch {
+		case marker == 0xE0: // JFIF
+			return true
+		case marker == 0xE2 && bytes.HasPrefix(payload, []byte("ICC_PROFILE")):
+			return true
+		case marker == 0xE1 && bytes.HasPrefix(payload, []byte("Exif\x00\x00")) && orientation == nil:
+			orientation = orientationTIFF(payload[6:])
+			return false
+		case marker >= 0xE1 && marker <= 0xEF, marker == 0xFE: // APPn, comment
+			return false
+		}
+		return true
+	})
+	if orientation == nil {
+		return stripped
+	}
+	return withExif(stripped, orientation)
+}
+
+func stripPNG(data []byte) []byte {
+	out := &bytes.Buffer{}
+	out.Write(data[:8])
+	i := 8
+	for i+12 <= len(data) {
+		l := int(binary.BigEndian.Uint32(data[i:]))
+		if i+12+l > len(data) {
+			break
+		}
+		switch string(data[i+4 : i+8]) {
+		case "eXIf":
+			if tiff := orientationTIFF(data[i+8 : i+8+l]); tiff != nil {
+				writePNGChunk(out, "eXIf", tiff)
+			}
+		case "tEXt", "zTXt", "iTXt", "tIME":
+		default:
+			out.Write(data[i : i+12+l])
+		}
+		i += 12 + l
+	}
+	out.Write(data[i:])
+	return out.Bytes()
+}
+
+func writePNGChunk(out *bytes.Buffer, typ string, data []byte) {
+	binary.Write(out, binary.BigEndian, uint32(len(data)))
+	out.WriteString(typ)
+	out.Write(data)
+	binary.Write(out, binary.BigEndian, crc32.ChecksumIEEE(append([]byte(typ), data...)))
+}
+
+// stripWebP drops the EXIF (but the orientation) and XMP chunks, and clears
+// their flags in the VP8X chunk.
+// @from: https://developers.google.com/speed/webp/docs/riff_container
+func stripWebP(data []byte) []byte {
+	const (
+		flagXMP = 0x04
+		flagExif = 0x08
+	)
+	out := &bytes.Buffer{}
+	out.Write(data[:12])
+	flags := -1 // offset in out
+	hasExif := false
+	i := 12
+	for i+8 <= len(data) {
+		fourCC := string(data[i : i+4])
+		l := int(binary.LittleEndian.Uint32(data[i+4:]))
+		if i+8+l > len(data) {
+			break
+		}
+		next := min(i+8+l+l%2, len(data)) // chunks are padded to an even size
+		switch fourCC {
+		case "EXIF":
+			// some encoders keep the prefix of the jpeg segment
+			payload := bytes.TrimPrefix(data[i+8:i+8+l], []byte("Exif\x00\x00"))
+			if tiff := orientationTIFF(payload); tiff != nil {
+				out.WriteString("EXIF")
+				binary.Write(out, binary.LittleEndian, uint32(len(tiff)))
+				out.Write(tiff)
+				if len(tiff)%2 == 1 {
+					out.WriteByte(0)
+				}
+				hasExif = true
+			}
+		case "XMP ":
+		default:
+			if fourCC == "VP8X" && l > 0 {
+				flags = out.Len() + 8
+			}
+			out.Write(data[i:next])
+		}
+		i = next
+	}
+	out.Write(data[i:])
+	bs := out.Bytes()
+	if flags >= 0 {
+		bs[flags] &^= flagXMP | flagExif
+		if hasExif {
+			bs[flags] |= flagExif
+		}
+	}
+	binary.LittleEndian.PutUint32(bs[4:], uint32(len(bs)-8))
+	return bs
+}
+
+// stripGIF drops the comment extensions, and the application extensions
+// but those that loop animations (NETSCAPE2.0 and ANIMEXTS1.0).
+// @from: https://www.w3.org/Graphics/GIF/spec-gif89a.txt
+func stripGIF(data []byte) []byte {
+	if len(data) < 13 {
+		return data
+	}
+	i := 13 // header and logical screen descriptor
+	if data[10]&0x80 != 0 {
+		i += 3 << (data[10]&0x07 + 1) // global color table
+	}
+	// subBlocks returns the offset after the data sub-blocks starting at j,
+	// or -1 if they are cut off
+	subBlocks := func(j int) int {
+		for j < len(data) && data[j] != 0 {
+			j += 1 + int(data[j])
+		}
+		if j >= len(data) {
+			return -1
+		}
+		return j + 1
+	}
+	if i > len(data) {
+		return data
+	}
+	out := &bytes.Buffer{}
+	out.Write(data[:i])
+	for i < len(data) {
+		start := i
+		switch data[i] {
+		case 0x21: // extension
+			if i+2 > len(data) {
+				return data
+			}
+			label := data[i+1]
+			if i = subBlocks(i + 2); i < 0 {
+				return data // not a gif after all, or a broken one
+			}
+			keep := true
+			switch label {
+			case 0xFE: // comment
+				keep = false
+			case 0xFF: // application
+				app := data[start+2 : i]
+				keep = len(app) >= 12 && (string(app[1:12]) == "NETSCAPE2.0" || string(app[1:12]) == "ANIMEXTS1.0")
+			}
+			if keep {
+				out.Write(data[start:i])
+			}
+		case 0x2C: // image
+			if i+10 > len(data) {
+				return data
+			}
+			flags := data[i+9]
+			i += 10
+			if flags&0x80 != 0 {
+				i += 3 << (flags&0x07 + 1) // local color table
+			}
+			if i = subBlocks(i + 1); i < 0 { // past the lzw minimum code size
+				return data
+			}
+			out.Write(data[start:i])
+		default: // trailer, or garbage after it
+			out.Write(data[i:])
+			return out.Bytes()
+		}
+	}
+	return out.Bytes()
+}
+
+// orientationTIFF is exif data (a tiff structure) with only the orientation
+// of the exif data tiff in it, nil if it has none (or the normal one).
+func orientationTIFF(tiff []byte) []byte {
+	order, ifd0, _ := readTIFF(tiff)
+	if order == nil || orientationOf(order, ifd0) == 1 {
+		return nil
+	}
+	for _, e := range ifd0 {
+		if e.Tag == exifOrientation {
+			return encodeTIFF(order, []exifEntry{e}, nil)
+		}
+	}
+	return nil
+}
+
+// withExif adds an exif segment with the tiff data to the jpeg file, right
+// after the start of image.
+func withExif(jpeg, tiff []byte) []byte {
+	app1 := append([]byte("Exif\x00\x00"), tiff...)
+	seg := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(seg[2:], uint16(len(app1)+2))
+	seg = append(seg, app1...)
+	return append(append(append([]byte{}, jpeg[:2]...), seg...), jpeg[2:]...)
+}
+
+// readExif returns the byte order and the entries of IFD0 and the Exif IFD of
+// a jpeg file.
+func readExif(data []byte) (order binary.ByteOrder, ifd0, exif []exifEntry) {
+	jpegSegments(data, func(marker byte, payload []byte) bool {
+		if marker != 0xE1 || !bytes.HasPrefix(payload, []byte("Exif\x00\x00")) || ifd0 != nil {
+			return true
+		}
+		order, ifd0, exif = readTIFF(payload[6:])
+		return true
+	})
+	return order, ifd0, exif
+}
+
+// readTIFF returns the byte order and the entries of IFD0 and the Exif IFD of
+// exif data, a nil order if it is none.
+func readTIFF(tiff []byte) (order binary.ByteOrder, ifd0, exif []exifEntry) {
+	if len(tiff) < 8 {
+		return nil, nil, nil
+	}
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, nil, nil
+	}
+	ifd0 = readIFD(order, tiff, order.Uint32(tiff[4:]))
+	for _, e := range ifd0 {
+		if e.Tag == exifIFDPointer && len(e.Value) == 4 {
+			exif = readIFD(order, tiff, order.Uint32(e.Value))
+		}
+	}
+	return order, ifd0, exif
+}
+
+func readIFD(order binary.ByteOrder, tiff []byte, offset uint32) (entries []exifEntry) {
+	if int(offset)+2 > len(tiff) {
+		return nil
+	}
+	n := int(order.Uint16(tiff[offset:]))
+	for i := 0; i < n; i++ {
+		at := int(offset) + 2 + 12*i
+		if at+12 > len(tiff) {
+			break
+		}
+		e := exifEntry{
+			Tag: order.Uint16(tiff[at:]),
+			Type: order.Uint16(tiff[at+2:]),
+			Count: order.Uint32(tiff[at+4:]),
+		}
+		size := exifTypeSize[e.Type] * e.Count
+		if size <= 4 {
+			e.Value = tiff[at+8 : at+8+int(size)]
+		} else {
+			valueAt := order.Uint32(tiff[at+8:])
+			if uint64(valueAt)+uint64(size) > uint64(len(tiff)) {
+				continue
+			}
+			e.Value = tiff[valueAt : valueAt+size]
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// exifOrientationOf returns the orientation (1-8) stored in a jpeg file, 1 if
+// there is none.
+func exifOrientationOf(data []byte) int {
+	order, ifd0, _ := readExif(data)
+	return orientationOf(order, ifd0)
+}
+
+func orientationOf(order binary.ByteOrder, ifd0 []exifEntry) int {
+	for _, e := range ifd0 {
+		if e.Tag == exifOrientation && e.Type == 3 && len(e.Value) >= 2 {
+			if o := int(order.Uint16(e.Value)); o >= 1 && o <= 8 {
+				return o
+			}
+		}
+	}
+	return 1
+}
+
+// applyOrientation transforms the pixels so that the image displays
+// correctly without its orientation tag.
+// @from: https://magnushoff.com/articles/jpeg-orientation/
+func applyOrientation(src image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return src
+	}
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if orientation >= 5 {
+		w, h = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = w-1-y, x
+			case 7:
+				dx, dy = w-1-y, h-1-x
+			case 8:
+				dx, dy = y, h-1-x
+			}
+			dst.Set(dx, dy, src.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return dst
+}
+
+// keepExifGroups copies the tags of the given groups from the jpeg src into the
+// (metadata free) jpeg dst.
+func keepExifGroups(src, dst []byte, groups []string) []byte {
+	order, srcIFD0, srcExif := readExif(src)
+	if order == nil {
+		return dst
+	}
+	var ifd0, exif []exifEntry
+	for _, g := range groups {
+		for _, e := range srcIFD0 {
+			for _, t := range exifGroups[g].IFD0 {
+				if e.Tag == t {
+					ifd0 = append(ifd0, e)
+				}
+			}
+		}
+		for _, e := range srcExif {
+			for _, t := range exifGroups[g].Exif {
+				if e.Tag == t {
+					exif = append(exif, e)
+				}
+			}
+		}
+	}
+	if len(ifd0) == 0 && len(exif) == 0 {
+		return dst
+	}
+	return withExif(dst, encodeTIFF(order, ifd0, exif))
+}
+
+// encodeTIFF writes a minimal tiff structure: IFD0 (with a pointer to the Exif
+// IFD if there are exif entries), followed by the Exif IFD.
+func encodeTIFF(order binary.ByteOrder, ifd0, exif []exifEntry) []byte {
+	buf := &bytes.Buffer{}
+	if order == binary.LittleEndian {
+		buf.WriteString("II")
+	} else {
+		buf.WriteString("MM")
+	}
+	binary.Write(buf, order, uint16(42))
+	binary.Write(buf, order, uint32(8))
+
+	if len(exif) > 0 {
+		ifd0 = append(ifd0, exifEntry{Tag: exifIFDPointer, Type: 4, Count: 1, Value: make([]byte, 4)})
+	}
+	ifd0Data := writeIFD(buf, order, ifd0)
+	if len(exif) > 0 {
+		// patch the pointer, the Exif IFD starts right after IFD0
+		for i, e := range ifd0 { // sorted by writeIFD
+			if e.Tag == exifIFDPointer {
+				order.PutUint32(buf.Bytes()[ifd0Data[i]:], uint32(buf.Len()))
+			}
+		}
+		writeIFD(buf, order, exif)
+	}
+	return buf.Bytes()
+}
+
+// writeIFD appends the IFD and its out of line values to buf, and returns the
+// offsets of the value fields of the entries.
+func writeIFD(buf *bytes.Buffer, order binary.ByteOrder, entries []exifEntry) (valueFields []int) {
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Tag < entries[j].Tag })
+	start := buf.Len()
+	dataAt := start + 2 + 12*len(entries) + 4
+	var data []byte
+	binary.Write(buf, order, uint16(len(entries)))
+	for _, e := range entries {
+		binary.Write(buf, order, e.Tag)
+		binary.Write(buf, order, e.Type)
+		binary.Write(buf, order, e.Count)
+		valueFields = append(valueFields, buf.Len())
+		if len(e.Value) <= 4 {
+			v := make([]byte, 4)
+			copy(v, e.Value)
+			buf.Write(v)
+		} else {
+			binary.Write(buf, order, uint32(dataAt+len(data)))
+			data = append(data, e.Value...)
+			if len(data)%2 == 1 {
+				data = append(data, 0) // values start on word boundaries
+			}
+		}
+	}
+	binary.Write(buf, order, uint32(0)) // no next IFD
+	buf.Write(data)
+	return valueFields
+}
@@ -0,0 +1,213 @@
+package site
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+const (
+	tagMake = 0x010F
+	tagModel = 0x0110
+	tagGPS = 0x8825
+	tagExposureTime = 0x829A
+)
+
+func testImage() image.Image {
+	img := image.NewPaletted(image.Rect(0, 0, 4, 2), []color.Color{color.Black, color.White})
+	img.SetColorIndex(1, 1, 1)
+	return img
+}
+
+// testTIFF is exif data with a camera, an orientation, a location (pointer
+// only) and an exposure time.
+func testTIFF(orientation uint16) []byte {
+	order := binary.LittleEndian
+	short := make([]byte, 2)
+	order.PutUint16(short, orientation)
+	exposure := make([]byte, 8)
+	order.PutUint32(exposure, 1)
+	order.PutUint32(exposure[4:], 100)
+	return encodeTIFF(order, []exifEntry{
+		{Tag: tagMake, Type: 2, Count: 6, Value: []byte("Canon\x00")},
+		{Tag: tagModel, Type: 2, Count: 4, Value: []byte("EOS\x00")},
+		{Tag: exifOrientation, Type: 3, Count: 1, Value: short},
+		{Tag: tagGPS, Type: 4, Count: 1, Value: make([]byte, 4)},
+	}, []exifEntry{
+		{Tag: tagExposureTime, Type: 5, Count: 1, Value: exposure},
+	})
+}
+
+func testJPEG(t *testing.T, tiff []byte) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	if err := jpeg.Encode(buf, testImage(), nil); err != nil {
+		t.Fatal(err)
+	}
+	return withExif(buf.Bytes(), tiff)
+}
+
+func tags(entries []exifEntry) []uint16 {
+	var ts []uint16
+	for _, e := range entries {
+		ts = append(ts, e.Tag)
+	}
+	return ts
+}
+
+func TestStripJPEG(t *testing.T) {
+	for _, tt := range []struct {
+		orientation uint16
+		want []uint16
+	}{
+		{6, []uint16{exifOrientation}},
+		{1, nil},
+	} {
+		got := stripMetadata(testJPEG(t, testTIFF(tt.orientation)))
+		_, ifd0, exif := readExif(got)
+		if !equalTags(tags(ifd0), tt.want) || exif != nil {
+			t.Errorf("orientation %d: kept %x and %x, want %x", tt.orientation, tags(ifd0), tags(exif), tt.want)
+		}
+		if o := exifOrientationOf(got); o != int(tt.orientation) {
+			t.Errorf("orientation %d: got %d", tt.orientation, o)
+		}
+		if _, err := jpeg.Decode(bytes.NewReader(got)); err != nil {
+			t.Errorf("orientation %d: %v", tt.orientation, err)
+		}
+	}
+}
+
+func TestKeepExifGroups(t *testing.T) {
+	src := testJPEG(t, testTIFF(6))
+	dst := stripMetadata(testJPEG(t, testTIFF(1)))
+	for _, tt := range []struct {
+		groups []string
+		ifd0, exif []uint16
+	}{
+		{[]string{"camera"}, []uint16{tagMake, tagModel}, nil},
+		{[]string{"exposure"}, []uint16{exifIFDPointer}, []uint16{tagExposureTime}},
+		{[]string{"camera", "exposure"}, []uint16{tagMake, tagModel, exifIFDPointer}, []uint16{tagExposureTime}},
+		{[]string{"date"}, nil, nil},
+	} {
+		order, ifd0, exif := readExif(keepExifGroups(src, dst, tt.groups))
+		if !equalTags(tags(ifd0), tt.ifd0) || !equalTags(tags(exif), tt.exif) {
+			t.Errorf("%v: kept %x and %x, want %x and %x", tt.groups, tags(ifd0), tags(exif), tt.ifd0, tt.exif)
+			continue
+		}
+		for _, e := range append(ifd0, exif...) {
+			switch e.Tag {
+			case tagMake:
+				if string(e.Value) != "Canon\x00" {
+					t.Errorf("%v: make %q", tt.groups, e.Value)
+				}
+			case tagExposureTime:
+				if len(e.Value) != 8 || order.Uint32(e.Value) != 1 || order.Uint32(e.Value[4:]) != 100 {
+					t.Errorf("%v: exposure time %x", tt.groups, e.Value)
+				}
+			}
+		}
+	}
+}
+
+func equalTags(a, b []uint16) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestStripPNG(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, testImage()); err != nil {
+		t.Fatal(err)
+	}
+	bs := buf.Bytes()
+	iend := len(bs) - 12
+	chunks := &bytes.Buffer{}
+	writePNGChunk(chunks, "tEXt", []byte("Author\x00someone"))
+	writePNGChunk(chunks, "eXIf", testTIFF(6))
+	got := stripMetadata(append(append(append([]byte{}, bs[:iend]...), chunks.Bytes()...), bs[iend:]...))
+	if bytes.Contains(got, []byte("tEXt")) || bytes.Contains(got, []byte("Canon")) {
+		t.Error("metadata kept")
+	}
+	if !bytes.Contains(got, []byte("eXIf")) {
+		t.Error("orientation dropped")
+	}
+	if _, err := png.Decode(bytes.NewReader(got)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStripWebP(t *testing.T) {
+	chunk := func(fourCC string, data []byte) []byte {
+		c := append([]byte(fourCC), binary.LittleEndian.AppendUint32(nil, uint32(len(data)))...)
+		c = append(c, data...)
+		if len(data)%2 == 1 {
+			c = append(c, 0)
+		}
+		return c
+	}
+	vp8x := make([]byte, 10)
+	vp8x[0] = 0x04 | 0x08 // xmp, exif
+	webp := func(chunks ...[]byte) []byte {
+		var body []byte
+		for _, c := range chunks {
+			body = append(body, c...)
+		}
+		bs := append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(4+len(body)))...)
+		return append(append(bs, "WEBP"...), body...)
+	}
+	image := chunk("VP8 ", []byte("not really an image"))
+	for _, tt := range []struct {
+		orientation uint16
+		flags byte
+	}{
+		{6, 0x08},
+		{1, 0},
+	} {
+		got := stripMetadata(webp(chunk("VP8X", vp8x), chunk("EXIF", testTIFF(tt.orientation)), chunk("XMP ", []byte("<x:xmpmeta/>")), image))
+		flags := append([]byte{}, vp8x...)
+		flags[0] = tt.flags
+		want := webp(chunk("VP8X", flags), image)
+		if tt.orientation != 1 {
+			want = webp(chunk("VP8X", flags), chunk("EXIF", orientationTIFF(testTIFF(tt.orientation))), image)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("orientation %d:\ngot  %q\nwant %q", tt.orientation, got, want)
+		}
+	}
+}
+
+func TestStripGIF(t *testing.T) {
+	buf := &bytes.Buffer{}
+	anim := &gif.GIF{Image: []*image.Paletted{testImage().(*image.Paletted), testImage().(*image.Paletted)}, Delay: []int{10, 10}}
+	if err := gif.EncodeAll(buf, anim); err != nil {
+		t.Fatal(err)
+	}
+	want := buf.Bytes()
+	// after the header, logical screen descriptor and global color table
+	at := 13
+	if want[10]&0x80 != 0 {
+		at += 3 << (want[10]&0x07 + 1)
+	}
+	comment := []byte{0x21, 0xFE, 3, 'a', 'b', 'c', 0}
+	xmp := append(append([]byte{0x21, 0xFF, 11}, "XMP DataXMP"...), 4, '<', 'x', '/', '>', 0)
+	src := append(append(append(append([]byte{}, want[:at]...), comment...), xmp...), want[at:]...)
+	got := stripMetadata(src)
+	if !bytes.Equal(got, want) {
+		t.Errorf("got  %x\nwant %x", got, want)
+	}
+	if _, err := gif.DecodeAll(bytes.NewReader(got)); err != nil {
+		t.Error(err)
+	}
+}
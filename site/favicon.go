@@ -0,0 +1,164 @@
+package site
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io/fs"
+	"log/slog"
+	"os"
+
+	"be/component"
+)
+
+type (
+	webManifest struct {
+		Name string `json:"name"`
+		ShortName string `json:"short_name"`
+		Description string `json:"description,omitempty"`
+		StartURL string `json:"start_url"`
+		Display string `json:"display"`
+		BackgroundColor string `json:"background_color,omitempty"`
+		ThemeColor string `json:"theme_color,omitempty"`
+		Icons []manifestIcon `json:"icons"`
+	}
+	manifestIcon struct {
+		Src string `json:"src"`
+		Sizes string `json:"sizes"`
+		Type string `json:"type"`
+	}
+)
+
+var (
+	// embedded into favicon.ico
+	icoSizes = []int{16, 32, 48}
+	// linked as png from every page
+	iconSizes = []int{16, 32}
+	manifestIconSizes = []int{192, 512}
+)
+
+const (
+	appleTouchIconSize = 180
+	manifestPath = "site.webmanifest"
+)
+
+func iconPath(size int) string {
+	return fmt.Sprintf("public/icons/icon-%dx%d.png", size, size)
+}
+
+// buildIcons generates the icons browsers (and operating systems, when the
+// site is added to the home screen) look for from the configured favicon.
+// favicon.ico and apple-touch-icon.png are placed at the root, where they
+// are also found without being linked.
+func (s *Site) buildIcons() error {
+	s.icons, s.manifest = nil, ""
+	if s.Config.Favicon == "" {
+		return nil
+	}
+	f, err := os.Open(s.Config.Favicon)
+	if errors.Is(err, fs.ErrNotExist) {
+		slog.Warn("favicon not found, not generating icons", "favicon", s.Config.Favicon)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	src, _, err := image.Decode(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %w", s.Config.Favicon, err)
+	}
+	if b := src.Bounds(); b.Dx() != b.Dy() {
+		return fmt.Errorf("%s: favicon must be square, is %dx%d", s.Config.Favicon, b.Dx(), b.Dy())
+	}
+
+	icon := func(size int) ([]byte, error) {
+		buf := &bytes.Buffer{}
+		err := png.Encode(buf, resize(src, size, size))
+		return buf.Bytes(), err
+	}
+
+	var ico [][]byte
+	for _, size := range icoSizes {
+		bs, err := icon(size)
+		if err != nil {
+			return err
+		}
+		ico = append(ico, bs)
+	}
+	s.Emit("favicon.ico", encodeICO(icoSizes, ico))
+	s.icons = append(s.icons, component.Icon{Rel: "icon", Sizes: "any", Href: "/favicon.ico"})
+
+	for _, size := range iconSizes {
+		bs, err := icon(size)
+		if err != nil {
+			return err
+		}
+		s.Emit(iconPath(size), bs)
+		s.icons = append(s.icons, component.Icon{
+			Rel: "icon",
+			Type: "image/png",
+			Sizes: fmt.Sprintf("%dx%d", size, size),
+			Href: "/" + iconPath(size),
+		})
+	}
+
+	bs, err := icon(appleTouchIconSize)
+	if err != nil {
+		return err
+	}
+	s.Emit("apple-touch-icon.png", bs)
+	s.icons = append(s.icons, component.Icon{Rel: "apple-touch-icon", Href: "/apple-touch-icon.png"})
+
+	manifest := webManifest{
+		Name: s.Config.BlogName,
+		ShortName: s.Config.BlogName,
+		Description: s.Config.Description,
+		StartURL: "/",
+		Display: "standalone",
+		BackgroundColor: s.Config.ThemeColor,
+		ThemeColor: s.Config.ThemeColor,
+	}
+	for _, size := range manifestIconSizes {
+		bs, err := icon(size)
+		if err != nil {
+			return err
+		}
+		s.Emit(iconPath(size), bs)
+		manifest.Icons = append(manifest.Icons, manifestIcon{
+			Src: "/" + iconPath(size),
+			Sizes: fmt.Sprintf("%dx%d", size, size),
+			Type: "image/png",
+		})
+	}
+	if bs, err = json.MarshalIndent(manifest, "", "\t"); err != nil {
+		return err
+	}
+	s.Emit(manifestPath, bs)
+	s.manifest = "/" + manifestPath
+	return nil
+}
+
+// encodeICO packs the png encoded images into an ico file.
+// @from: https://en.wikipedia.org/wiki/ICO_(file_format)
+func encodeICO(sizes []int, pngs [][]byte) []byte {
+	buf := &bytes.Buffer{}
+	le := binary.LittleEndian
+	binary.Write(buf, le, [3]uint16{0, 1, uint16(len(pngs))}) // reserved, type, count
+	offset := 6 + 16*len(pngs)
+	for i, data := range pngs {
+		dim := uint8(sizes[i] % 256) // 0 means 256
+		buf.Write([]byte{dim, dim, 0, 0}) // width, height, palette, reserved
+		binary.Write(buf, le, [2]uint16{1, 32}) // color planes, bits per pixel
+		binary.Write(buf, le, [2]uint32{uint32(len(data)), uint32(offset)})
+		offset += len(data)
+	}
+	for _, data := range pngs {
+		buf.Write(data)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,89 @@
+package site
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// @from: https://www.rssboard.org/rss-specification
+type (
+	rss struct {
+		XMLName xml.Name `xml:"rss"`
+		Version string `xml:"version,attr"`
+		AtomNS string `xml:"xmlns:atom,attr"`
+		Channel rssChannel `xml:"channel"`
+	}
+	rssChannel struct {
+		Title string `xml:"title"`
+		Link string `xml:"link"`
+		Description string `xml:"description"`
+		Language string `xml:"language"`
+		LastBuildDate string `xml:"lastBuildDate,omitempty"`
+		Self rssAtomLink `xml:"atom:link"`
+		Items []rssItem `xml:"item"`
+	}
+	rssAtomLink struct {
+		Href string `xml:"href,attr"`
+		Rel string `xml:"rel,attr"`
+		Type string `xml:"type,attr"`
+	}
+	rssItem struct {
+		Title string `xml:"title"`
+		Link string `xml:"link"`
+		GUID string `xml:"guid"`
+		PubDate string `xml:"pubDate,omitempty"`
+		Description string `xml:"description,omitempty"`
+		Author string `xml:"author,omitempty"`
+		Categories []string `xml:"category"`
+	}
+)
+
+// buildFeeds writes an rss feed per language.
+func (s *Site) buildFeeds() error {
+	for _, lang := range s.Languages() {
+		p := s.LangPath(lang, "rss.xml")
+		feed := rss{
+			Version: "2.0",
+			AtomNS: "http://www.w3.org/2005/Atom",
+			Channel: rssChannel{
+				Title: s.Config.BlogName,
+				Link: s.URL(s.LangPath(lang, "index.html")),
+				Description: s.Config.Description,
+				Language: lang,
+				Self: rssAtomLink{
+					Href: s.URL(p),
+					Rel: "self",
+					Type: "application/rss+xml",
+				},
+			},
+		}
+		if lm := s.lastModIn(lang); !lm.IsZero() {
+			feed.Channel.LastBuildDate = lm.Format(time.RFC1123Z)
+		}
+		for _, e := range s.EntriesIn(lang) {
+			if e.Data.Meta.Outdated {
+				continue
+			}
+			item := rssItem{
+				Title: e.Data.Title,
+				Link: s.URL(e.Path()),
+				GUID: s.URL(e.Path()),
+				Description: e.Data.Meta.Description,
+			}
+			if !e.Data.Meta.Published.IsZero() {
+				item.PubDate = e.Data.Meta.Published.Format(time.RFC1123Z)
+			}
+			if e.Data.Author.EMail != "" {
+				item.Author = e.Data.Author.EMail + " (" + e.Data.Author.Name + ")"
+			}
+			for _, t := range e.Data.Tags {
+				item.Categories = append(item.Categories, t.Name())
+			}
+			feed.Channel.Items = append(feed.Channel.Items, item)
+		}
+		if err := s.emitXML(p, feed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package site
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Extensions of the public files whose names get fingerprinted.
+var fingerprinted = []string{".css", ".js"}
+
+// Site relative or absolute reference to a public file, in an attribute or
+// url().
+var publicRef = regexp.MustCompile(`/public/[^"'\s()<>]+`)
+
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:8]
+}
+
+// fingerprint renames stylesheets and scripts to contain the hash of their
+// content (styles.css => styles.a1b2c3d4.css), and rewrites all references to
+// them in the html pages. A changed file thus always gets a new url, and
+// they may be cached forever.
+// Only files emitted since the last postprocess are considered, the renames
+// of earlier ones are remembered.
+func (s *Site) fingerprint() {
+	for _, p := range s.pendingOutputs() {
+		ext := path.Ext(p)
+		if !strings.HasPrefix(p, "public/") || !slices.Contains(fingerprinted, ext) {
+			continue
+		}
+		data := s.outputs[p]
+		renamed := strings.TrimSuffix(p, ext) + "." + contentHash(data) + ext
+		s.remove(p)
+		s.Emit(renamed, data)
+		s.renames["/"+p] = "/" + renamed
+	}
+	if len(s.renames) == 0 {
+		return
+	}
+	for _, p := range s.pendingOutputs() {
+		if path.Ext(p) != ".html" {
+			continue
+		}
+		s.outputs[p] = publicRef.ReplaceAllFunc(s.outputs[p], func(ref []byte) []byte {
+			if renamed, ok := s.renames[string(ref)]; ok {
+				return []byte(renamed)
+			}
+			return ref
+		})
+	}
+}
@@ -0,0 +1,267 @@
+package site
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+)
+
+type Headers struct {
+	// How the headers are emitted, one of HeadersNetlify or HeadersHtaccess.
+	// None are if empty.
+	Format string `json:"format"`
+	// Value of Strict-Transport-Security, e.g. "max-age=63072000;
+	// includeSubDomains". Not sent if empty.
+	HSTS string `json:"hsts"`
+	ReferrerPolicy string `json:"referrer_policy"`
+	// Sources allowed by the Content-Security-Policy in addition to those
+	// the pages use, by directive, e.g. {"connect-src": ["https://plausible.io"]}.
+	CSP map[string][]string `json:"csp"`
+	// Sent as they are, e.g. {"Permissions-Policy": "camera=()"}.
+	Extra map[string]string `json:"extra"`
+}
+
+const (
+	// A single _headers file, as understood by Netlify, Cloudflare Pages, ...
+	HeadersNetlify = "_headers"
+	// Part of the .htaccess file for Apache (with mod_headers).
+	HeadersHtaccess = "htaccess"
+)
+
+// Types of scripts run by the browser, other script elements hold data
+// (e.g. application/ld+json).
+var javascriptTypes = map[string]bool{"": true, "text/javascript": true, "application/javascript": true, "module": true}
+
+// buildServerConfig emits the files configuring the web server: the
+// redirects (see buildRedirects) and the security headers.
+func (s *Site) buildServerConfig() error {
+	var headers []string
+	if s.Config.Headers.Format != "" {
+		headers = s.securityHeaders()
+	}
+	switch s.Config.Headers.Format {
+	case "":
+	case HeadersNetlify:
+		s.Emit("_headers", []byte("/*\n  "+strings.Join(headers, "\n  ")+"\n"))
+	case HeadersHtaccess:
+		// Header always set <name> "<value>"
+		for i, h := range headers {
+			name, value, _ := strings.Cut(h, ": ")
+			headers[i] = fmt.Sprintf("\tHeader always set %s %q", name, value)
+		}
+	default:
+		return fmt.Errorf("invalid headers format: %s", s.Config.Headers.Format)
+	}
+	rules := s.redirectRules
+	if len(rules) == 0 && s.Config.Headers.Format != HeadersHtaccess {
+		return nil
+	}
+	if s.Config.Redirects == RedirectNetlify {
+		s.Emit("_redirects", []byte(strings.Join(rules, "\n")+"\n"))
+		rules = nil
+	}
+	if s.Config.Headers.Format == HeadersHtaccess {
+		rules = append(rules, "<IfModule mod_headers.c>")
+		rules = append(rules, headers...)
+		rules = append(rules, "</IfModule>")
+	}
+	if len(rules) > 0 {
+		s.Emit(".htaccess", []byte(strings.Join(rules, "\n")+"\n"))
+	}
+	return nil
+}
+
+// securityHeaders are sent with every response, as "Name: value".
+func (s *Site) securityHeaders() []string {
+	cfg := s.Config.Headers
+	headers := []string{
+		"Content-Security-Policy: " + s.contentSecurityPolicy(),
+		"X-Content-Type-Options: nosniff",
+		"X-Frame-Options: DENY",
+	}
+	if cfg.ReferrerPolicy != "" {
+		headers = append(headers, "Referrer-Policy: "+cfg.ReferrerPolicy)
+	}
+	if cfg.HSTS != "" {
+		headers = append(headers, "Strict-Transport-Security: "+cfg.HSTS)
+	}
+	var extra []string
+	for name, value := range cfg.Extra {
+		extra = append(extra, name+": "+value)
+	}
+	sort.Strings(extra)
+	return append(headers, extra...)
+}
+
+// contentSecurityPolicy allows what the emitted pages use: the origins of
+// the scripts, stylesheets, images, ... they link, and their inline scripts
+// by hash. Inline scripts are hashed as they are served, call it after the
+// pages are minified.
+// Scripts of other sites (e.g. of the analytics) are allowed to connect back
+// to them.
+func (s *Site) contentSecurityPolicy() string {
+	sources := map[string]map[string]bool{}
+	allow := func(directive, source string) {
+		if sources[directive] == nil {
+			sources[directive] = map[string]bool{"'self'": true}
+		}
+		if source != "" {
+			sources[directive][source] = true
+		}
+	}
+	allowURL := func(directive, u string) {
+		allow(directive, s.cspSource(u))
+		if directive == "script-src" {
+			allow("connect-src", s.cspSource(u))
+		}
+	}
+	hash := func(bs []byte) string {
+		sum := sha256.Sum256(bs)
+		return "'sha256-" + base64.StdEncoding.EncodeToString(sum[:]) + "'"
+	}
+	inlineStyles := false
+	for _, p := range s.Outputs() {
+		if path.Ext(p) != ".html" {
+			continue
+		}
+		doc := s.outputs[p]
+		for _, t := range htmlTags(doc) {
+			if t.End {
+				continue
+			}
+			for name, value := range t.Attrs {
+				if name == "style" {
+					inlineStyles = true
+				} else if strings.HasPrefix(name, "on") {
+					// event handlers are only allowed by hash with
+					// 'unsafe-hashes'
+					allow("script-src", "'unsafe-hashes'")
+					allow("script-src", hash([]byte(value)))
+				}
+			}
+			switch t.Name {
+			case "script":
+				if src, ok := t.Attrs["src"]; ok {
+					allowURL("script-src", src)
+				} else if javascriptTypes[strings.ToLower(t.Attrs["type"])] {
+					allow("script-src", hash(elementText(doc, t)))
+				}
+			case "style":
+				allow("style-src", hash(elementText(doc, t)))
+			case "link":
+				switch rel := strings.Fields(strings.ToLower(t.Attrs["rel"])); {
+				case slices.Contains(rel, "stylesheet"):
+					allowURL("style-src", t.Attrs["href"])
+				case slices.Contains(rel, "icon"), slices.Contains(rel, "apple-touch-icon"):
+					allowURL("img-src", t.Attrs["href"])
+				case slices.Contains(rel, "manifest"):
+					allowURL("manifest-src", t.Attrs["href"])
+				}
+			case "img", "source", "video", "audio", "track":
+				directive := "img-src"
+				if t.Name != "img" && (t.Name != "source" || t.Attrs["srcset"] == "") {
+					directive = "media-src"
+				}
+				if src, ok := t.Attrs["src"]; ok {
+					allowURL(directive, src)
+				}
+				for _, c := range strings.Split(t.Attrs["srcset"], ",") {
+					if fields := strings.Fields(c); len(fields) > 0 {
+						allowURL(directive, fields[0])
+					}
+				}
+				if poster, ok := t.Attrs["poster"]; ok {
+					allowURL("img-src", poster)
+				}
+			case "iframe":
+				allowURL("frame-src", t.Attrs["src"])
+			case "form":
+				allowURL("form-action", t.Attrs["action"])
+			}
+		}
+	}
+	if inlineStyles {
+		// hashes would disable 'unsafe-inline', which the attributes need
+		allow("style-src", "'unsafe-inline'")
+		for src := range sources["style-src"] {
+			if strings.HasPrefix(src, "'sha256-") {
+				delete(sources["style-src"], src)
+			}
+		}
+	}
+	for directive, srcs := range s.Config.Headers.CSP {
+		for _, src := range srcs {
+			allow(directive, src)
+		}
+	}
+	sources["object-src"] = map[string]bool{"'none'": true}
+	sources["frame-ancestors"] = map[string]bool{"'none'": true}
+	sources["base-uri"] = map[string]bool{"'self'": true}
+	directives := []string{"default-src 'self'"}
+	var names []string
+	for directive := range sources {
+		names = append(names, directive)
+	}
+	sort.Strings(names)
+	for _, directive := range names {
+		if directive == "default-src" {
+			continue
+		}
+		var srcs []string
+		for src := range sources[directive] {
+			srcs = append(srcs, src)
+		}
+		sort.Slice(srcs, func(i, j int) bool {
+			// keywords first
+			qi, qj := strings.HasPrefix(srcs[i], "'"), strings.HasPrefix(srcs[j], "'")
+			if qi != qj {
+				return qi
+			}
+			return srcs[i] < srcs[j]
+		})
+		directives = append(directives, directive+" "+strings.Join(srcs, " "))
+	}
+	return strings.Join(directives, "; ")
+}
+
+// cspSource is how the policy allows the url: empty if 'self' does, the
+// scheme for data: and other non-http urls, the origin otherwise.
+func (s *Site) cspSource(link string) string {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil {
+		return ""
+	}
+	base, _ := url.Parse(s.Config.BaseURL)
+	switch {
+	case u.Scheme == "" && u.Host == "":
+		return "" // relative
+	case u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "":
+		return u.Scheme + ":"
+	case base != nil && u.Host == base.Host:
+		return ""
+	case u.Scheme == "":
+		return "https://" + u.Host // //host/path
+	default:
+		return u.Scheme + "://" + u.Host
+	}
+}
+
+// elementText is the content of a script or style element, up to its end
+// tag.
+func elementText(doc []byte, t htmlTag) []byte {
+	m := tagPattern.FindIndex(doc[t.Pos:])
+	if m == nil {
+		return nil
+	}
+	text := doc[t.Pos+m[1]:]
+	if end := bytes.Index(bytes.ToLower(text), []byte("</"+t.Name)); end >= 0 {
+		text = text[:end]
+	}
+	return text
+}
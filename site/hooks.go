@@ -0,0 +1,88 @@
+package site
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// Hooks are shell commands (run with sh -c) by stage, e.g. compiling the
+// stylesheets before the build, or purging the cache of a CDN after
+// deploying. They run in order, the first failing one aborts the build or
+// deploy.
+//
+// The commands get the stage as $BLOG_HOOK, the output directory as
+// $BLOG_OUTPUT, the base url as $BLOG_BASE_URL and, when deploying, the name
+// of the target as $BLOG_TARGET.
+type Hooks struct {
+	PreBuild []string `json:"pre_build"`
+	// Run after the outputs are written.
+	PostBuild []string `json:"post_build"`
+	PreDeploy []string `json:"pre_deploy"`
+	PostDeploy []string `json:"post_deploy"`
+}
+
+// Stages hooks run at.
+const (
+	HookPreBuild = "pre_build"
+	HookPostBuild = "post_build"
+	HookPreDeploy = "pre_deploy"
+	HookPostDeploy = "post_deploy"
+)
+
+// HookFunc is a hook registered from Go, target is the name of the deploy
+// target (empty for the build stages).
+type HookFunc func(s *Site, target string) error
+
+// AddHook registers fn to run at the stage, after the commands of the config.
+func (s *Site) AddHook(stage string, fn HookFunc) {
+	if s.hooks == nil {
+		s.hooks = map[string][]HookFunc{}
+	}
+	s.hooks[stage] = append(s.hooks[stage], fn)
+}
+
+// RunHooks runs the commands of the config and the functions registered for
+// the stage. Build and Deploy do not run them on their own, the commands of
+// blog do (serve only runs them when publishing, the outputs of pre_build
+// hooks would trigger rebuilds in development).
+func (s *Site) RunHooks(stage, target string) error {
+	var cmds []string
+	switch stage {
+	case HookPreBuild:
+		cmds = s.Config.Hooks.PreBuild
+	case HookPostBuild:
+		cmds = s.Config.Hooks.PostBuild
+	case HookPreDeploy:
+		cmds = s.Config.Hooks.PreDeploy
+	case HookPostDeploy:
+		cmds = s.Config.Hooks.PostDeploy
+	default:
+		return fmt.Errorf("unknown hook stage: %s", stage)
+	}
+	for _, c := range cmds {
+		slog.Info("running hook", "stage", stage, "command", c)
+		start := time.Now()
+		cmd := exec.Command("sh", "-c", c)
+		// stdout is for the reports of the commands
+		cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+		cmd.Env = append(os.Environ(),
+			"BLOG_HOOK="+stage,
+			"BLOG_OUTPUT="+s.Config.Output,
+			"BLOG_BASE_URL="+s.Config.BaseURL,
+			"BLOG_TARGET="+target,
+		)
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("%s hook %q: %w", stage, c, err)
+		}
+		slog.Debug("hook done", "stage", stage, "command", c, "duration", time.Since(start).Round(time.Millisecond).String())
+	}
+	for i, fn := range s.hooks[stage] {
+		if err := fn(s, target); err != nil {
+			return fmt.Errorf("%s hook #%d: %w", stage, i+1, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,370 @@
+package site
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/draw"
+
+	"be/component"
+)
+
+type (
+	imageVariant struct {
+		// Site relative url of the variant.
+		Path string
+		Width, Height int
+		// Site relative urls of the same variant in other formats, by
+		// mime type.
+		Formats map[string]string
+	}
+
+	sourceImage struct {
+		Source string
+		bs []byte
+		hash string
+		config image.Config
+		format string
+		orientation int
+		// guards decoded and variants, entries using the same image may be
+		// built at the same time
+		mu sync.Mutex
+		decoded image.Image
+		// by width and kept exif groups
+		variants map[string]imageVariant
+	}
+)
+
+const (
+	defaultImageSize = "medium"
+	defaultImageKind = "inline"
+)
+
+// imageSource finds the file an image path of the entry refers to.
+// Paths starting with /public/ are looked up in the public directory, all
+// other paths are relative to the source of the entry.
+func (s *Site) imageSource(e *Entry, p string) string {
+	if rel, ok := strings.CutPrefix(p, "/public/"); ok {
+		return filepath.Join(s.Config.Public, filepath.FromSlash(rel))
+	}
+	return filepath.Join(filepath.Dir(e.Source), filepath.FromSlash(p))
+}
+
+// processImages generates the variants needed by every image of the entry,
+// points the images at the variant of the requested size, and offers all
+// widths of the image kind as srcset.
+func (s *Site) processImages(e *Entry) error {
+	var errs []error
+	component.Walk(e.Data.Content, func(c component.ContentElement) {
+		img, ok := c.(*component.Image)
+		if !ok || img.Path == "" || strings.Contains(img.Path, "://") {
+			return
+		}
+		if err := s.processImage(e, img, e.Data.Meta.KeepExif); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", e.Source, err))
+		}
+	})
+	return errors.Join(errs...)
+}
+
+func (s *Site) processImage(e *Entry, img *component.Image, keep []string) error {
+	for _, g := range keep {
+		if _, ok := exifGroups[g]; !ok {
+			return fmt.Errorf("unknown exif group: %s", g)
+		}
+	}
+	src, err := s.loadImage(s.imageSource(e, img.Path))
+	if err != nil {
+		return err
+	}
+	size := img.Size
+	if size == "" {
+		size = defaultImageSize
+	}
+	width, ok := s.Config.ImageSizes[size]
+	if !ok {
+		return fmt.Errorf("unknown image size: %s", size)
+	}
+	kind := img.Kind
+	if kind == "" {
+		kind = defaultImageKind
+	}
+	k, ok := s.Config.ImageKinds[kind]
+	if !ok {
+		return fmt.Errorf("unknown image kind: %s", kind)
+	}
+
+	v, err := s.imageVariant(src, width, keep)
+	if err != nil {
+		return err
+	}
+	img.Path = "/" + v.Path
+	img.Width, img.Height = v.Width, v.Height
+	largest := 0
+	for _, w := range s.Config.ImageSizes {
+		largest = max(largest, w)
+	}
+	full, err := s.imageVariant(src, largest, keep)
+	if err != nil {
+		return err
+	}
+	img.Full = "/" + full.Path
+
+	img.SrcSet = nil
+	img.Sizes = k.Sizes
+	seen := map[int]bool{}
+	widths := append([]int{}, k.Widths...)
+	sort.Ints(widths)
+	var variants []imageVariant
+	for _, w := range widths {
+		v, err := s.imageVariant(src, w, keep)
+		if err != nil {
+			return err
+		}
+		if !seen[v.Width] {
+			seen[v.Width] = true
+			variants = append(variants, v)
+			img.SrcSet = append(img.SrcSet, component.ImageSource{Src: "/" + v.Path, Width: v.Width})
+		}
+	}
+
+	// modern formats first, the browser picks the first one it supports
+	img.Sources = nil
+	for _, f := range imageFormats {
+		source := component.PictureSource{Type: f.Type}
+		for _, v := range variants {
+			if p, ok := v.Formats[f.Type]; ok {
+				source.SrcSet = append(source.SrcSet, component.ImageSource{Src: "/" + p, Width: v.Width})
+			}
+		}
+		if len(source.SrcSet) == len(variants) && len(variants) > 0 {
+			img.Sources = append(img.Sources, source)
+		}
+	}
+	return nil
+}
+
+type imageFormat struct {
+	Name, Type string
+	// Command converting the file at {in} into {out}.
+	Command []string
+}
+
+var imageFormats = []imageFormat{
+	{Name: "avif", Type: "image/avif", Command: []string{"avifenc", "--speed", "6", "-q", "60", "{in}", "{out}"}},
+	{Name: "webp", Type: "image/webp", Command: []string{"cwebp", "-quiet", "-q", "80", "{in}", "-o", "{out}"}},
+}
+
+// convertImage fails if the encoder for the format is not installed. Dev
+// builds return nil (and warn once per format) instead, the image is then
+// only published in its original format.
+func (s *Site) convertImage(f imageFormat, data []byte) ([]byte, error) {
+	if _, err := exec.LookPath(f.Command[0]); err != nil {
+		if !s.Config.Dev {
+			return nil, fmt.Errorf("converting images to %s requires %s, install it or remove %s from image_formats", f.Name, f.Command[0], f.Name)
+		}
+		s.warnOnce(f.Command[0], "image encoder not found, images are published without this format", "encoder", f.Command[0], "format", f.Name)
+		return nil, nil
+	}
+	dir, err := os.MkdirTemp("", "be-image-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	in, out := filepath.Join(dir, "in"), filepath.Join(dir, "out."+f.Name)
+	if err := os.WriteFile(in, data, 0644); err != nil {
+		return nil, err
+	}
+	args := make([]string, len(f.Command)-1)
+	for i, a := range f.Command[1:] {
+		args[i] = strings.NewReplacer("{in}", in, "{out}", out).Replace(a)
+	}
+	if bs, err := exec.Command(f.Command[0], args...).CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("%s: %w: %s", f.Command[0], err, bs)
+	}
+	return os.ReadFile(out)
+}
+
+func (s *Site) loadImage(p string) (*sourceImage, error) {
+	s.mu.Lock()
+	img, ok := s.images[p]
+	s.mu.Unlock()
+	if ok {
+		return img, nil
+	}
+	bs, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(bs))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", p, err)
+	}
+	sum := sha256.Sum256(bs)
+	img = &sourceImage{
+		Source: p,
+		bs: bs,
+		hash: hex.EncodeToString(sum[:]),
+		config: cfg,
+		format: format,
+		orientation: 1,
+		variants: map[string]imageVariant{},
+	}
+	if format == "jpeg" {
+		img.orientation = exifOrientationOf(bs)
+		if img.orientation >= 5 { // rotated by 90°
+			img.config.Width, img.config.Height = cfg.Height, cfg.Width
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if loaded, ok := s.images[p]; ok { // by another entry in the meantime
+		return loaded, nil
+	}
+	s.images[p] = img
+	return img, nil
+}
+
+// imageVariant scales the image to width (but never up), reusing the result
+// of previous builds if the source did not change.
+// The variant carries no metadata, except for the exif groups to keep (jpeg
+// only).
+func (s *Site) imageVariant(img *sourceImage, width int, keepExif []string) (imageVariant, error) {
+	width = min(width, img.config.Width)
+	if img.format != "jpeg" {
+		keepExif = nil
+	}
+	key := strings.Join(append([]string{strconv.Itoa(width)}, keepExif...), "-")
+	img.mu.Lock()
+	defer img.mu.Unlock()
+	if v, ok := img.variants[key]; ok {
+		return v, nil
+	}
+	height := img.config.Height * width / img.config.Width
+	ext := ".png"
+	if img.format == "jpeg" {
+		ext = ".jpg"
+	}
+
+	cached := filepath.Join(s.Config.Cache, "images", fmt.Sprintf("%s-%s%s", img.hash, key, ext))
+	out, err := os.ReadFile(cached)
+	if errors.Is(err, fs.ErrNotExist) {
+		if img.decoded == nil {
+			if img.decoded, _, err = image.Decode(bytes.NewReader(img.bs)); err != nil {
+				return imageVariant{}, fmt.Errorf("%s: %w", img.Source, err)
+			}
+			img.decoded = applyOrientation(img.decoded, img.orientation)
+		}
+		if out, err = encodeImage(resize(img.decoded, width, height), img.format); err != nil {
+			return imageVariant{}, fmt.Errorf("%s: %w", img.Source, err)
+		}
+		if len(keepExif) > 0 {
+			out = keepExifGroups(img.bs, out, keepExif)
+		}
+		if err = writeCache(cached, out); err != nil {
+			return imageVariant{}, err
+		}
+	} else if err != nil {
+		return imageVariant{}, err
+	}
+
+	base := strings.TrimSuffix(filepath.Base(img.Source), filepath.Ext(img.Source))
+	v := imageVariant{
+		Path: hashedImagePath(base, width, out, ext),
+		Width: width,
+		Height: height,
+		Formats: map[string]string{},
+	}
+	s.Emit(v.Path, out)
+
+	for _, f := range imageFormats {
+		if !slices.Contains(s.Config.ImageFormats, f.Name) {
+			continue
+		}
+		cached := filepath.Join(s.Config.Cache, "images", fmt.Sprintf("%s-%s.%s", img.hash, key, f.Name))
+		conv, err := os.ReadFile(cached)
+		if errors.Is(err, fs.ErrNotExist) {
+			if conv, err = s.convertImage(f, out); err != nil {
+				return v, fmt.Errorf("%s: %w", img.Source, err)
+			}
+			if conv == nil {
+				continue // encoder not available
+			}
+			if err = writeCache(cached, conv); err != nil {
+				return v, err
+			}
+		} else if err != nil {
+			return v, err
+		}
+		p := hashedImagePath(base, width, conv, "."+f.Name)
+		s.Emit(p, conv)
+		v.Formats[f.Type] = p
+	}
+
+	img.variants[key] = v
+	return v, nil
+}
+
+func hashedImagePath(base string, width int, data []byte, ext string) string {
+	return path.Join("images", fmt.Sprintf("%s-%dw.%s%s", base, width, contentHash(data), ext))
+}
+
+func resize(src image.Image, width, height int) image.Image {
+	if src.Bounds().Dx() == width && src.Bounds().Dy() == height {
+		return src
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Src, nil)
+	return dst
+}
+
+func encodeImage(img image.Image, format string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	var err error
+	if format == "jpeg" {
+		err = jpeg.Encode(buf, img, &jpeg.Options{Quality: 85})
+	} else {
+		err = png.Encode(buf, img)
+	}
+	return buf.Bytes(), err
+}
+
+// writeCache writes the file atomically, concurrent builds never see it half
+// written.
+func writeCache(p string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(p), ".tmp-")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), p)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
@@ -0,0 +1,64 @@
+package site
+
+import (
+	"bytes"
+	"html/template"
+	"time"
+
+	"be/component"
+)
+
+// buildIndex renders an index page per language.
+func (s *Site) buildIndex() error {
+	for _, lang := range s.Languages() {
+		p := s.LangPath(lang, "index.html")
+		index := &component.IndexData{
+			BlogName: s.Config.BlogName,
+			Title: s.Config.BlogName,
+			Description: s.Config.Description,
+			Author: s.Config.Author,
+			Meta: component.Meta{
+				Language: lang,
+				CanonicalURL: s.URL(p),
+				FeedURL: s.URL(s.LangPath(lang, "rss.xml")),
+				Stylesheets: s.stylesheets,
+				Scripts: s.scripts,
+				Icons: s.icons,
+				Manifest: s.manifest,
+				ThemeColor: s.Config.ThemeColor,
+				ThemeToggle: s.Config.Themes.Toggle,
+				Analytics: template.HTML(s.Config.Analytics),
+			},
+			Languages: s.languageIndexes(lang),
+			Lists: []component.PostList{
+				{Title: "Recent Posts", Posts: s.Recent(lang, len(s.Entries))},
+				{Title: "Archived Posts", Posts: s.Outdated(lang)},
+			},
+		}
+		s.applyIdentity(&index.Meta)
+		lm := s.lastModIn(lang)
+		index.Meta.Published = lm
+		buf := &bytes.Buffer{}
+		if err := component.RenderIndex(buf, index); err != nil {
+			return err
+		}
+		s.Emit(p, buf.Bytes())
+		s.Pages = append(s.Pages, Page{
+			Path: p,
+			LastMod: lm,
+			ChangeFreq: "weekly",
+		})
+	}
+	return nil
+}
+
+// lastModIn returns the most recent modification of any entry of the
+// language.
+func (s *Site) lastModIn(lang string) (lm time.Time) {
+	for _, e := range s.EntriesIn(lang) {
+		if e.LastMod.After(lm) {
+			lm = e.LastMod
+		}
+	}
+	return lm
+}
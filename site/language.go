@@ -0,0 +1,86 @@
+package site
+
+import (
+	"path"
+	"sort"
+
+	"be/component"
+)
+
+// Languages lists all languages entries are written in, the default language
+// first.
+func (s *Site) Languages() []string {
+	seen := map[string]bool{s.Config.Language: true}
+	langs := []string{}
+	for _, e := range s.Entries {
+		if l := e.Data.Meta.Language; !seen[l] {
+			seen[l] = true
+			langs = append(langs, l)
+		}
+	}
+	sort.Strings(langs)
+	return append([]string{s.Config.Language}, langs...)
+}
+
+func (s *Site) EntriesIn(lang string) (es []*Entry) {
+	for _, e := range s.Entries {
+		if e.Data.Meta.Language == lang {
+			es = append(es, e)
+		}
+	}
+	return es
+}
+
+// LangPath places p inside the directory of the language.
+// The default language lives at the root of the site.
+func (s *Site) LangPath(lang, p string) string {
+	if lang == s.Config.Language {
+		return p
+	}
+	return path.Join(lang, p)
+}
+
+// translationKey is the same for an entry and all its translations.
+func translationKey(e *Entry) string {
+	if e.Data.TranslationOf != "" {
+		return e.Data.TranslationOf
+	}
+	return e.Slug
+}
+
+// linkTranslations lets each entry know about its translations.
+func (s *Site) linkTranslations() {
+	groups := map[string][]*Entry{}
+	for _, e := range s.Entries {
+		k := translationKey(e)
+		groups[k] = append(groups[k], e)
+	}
+	for _, e := range s.Entries {
+		e.Data.Languages = nil
+		for _, t := range groups[translationKey(e)] {
+			if t == e {
+				continue
+			}
+			e.Data.Languages = append(e.Data.Languages, component.Language{
+				Link: s.URL(t.Path()),
+				Language: component.LanguageName(t.Data.Meta.Language),
+				HrefLang: t.Data.Meta.Language,
+			})
+		}
+	}
+}
+
+// languageIndexes links the index pages of all languages but lang.
+func (s *Site) languageIndexes(lang string) (ls []component.Language) {
+	for _, l := range s.Languages() {
+		if l == lang {
+			continue
+		}
+		ls = append(ls, component.Language{
+			Link: s.URL(s.LangPath(l, "index.html")),
+			Language: component.LanguageName(l),
+			HrefLang: l,
+		})
+	}
+	return ls
+}
@@ -0,0 +1,42 @@
+package site
+
+import (
+	"errors"
+	"fmt"
+
+	"be/component"
+)
+
+// resolveLinks points (link (ref slug)) at the linked entry, and lets every
+// entry know which other entries link to it.
+func (s *Site) resolveLinks() error {
+	bySlug := map[string]*Entry{}
+	for _, e := range s.Entries {
+		bySlug[e.Slug] = e
+		e.Data.Backlinks = nil
+	}
+	var errs []error
+	for _, e := range s.Entries {
+		linked := map[*Entry]bool{}
+		component.Walk(e.Data.Content, func(c component.ContentElement) {
+			link, ok := c.(*component.Link)
+			if !ok || link.Ref == "" {
+				return
+			}
+			target, ok := bySlug[link.Ref]
+			if !ok {
+				errs = append(errs, fmt.Errorf("%s: link to unknown entry: %s", e.Source, link.Ref))
+				return
+			}
+			link.Link = Canonical(target.Path())
+			if link.Text == "" {
+				link.Text = target.Data.Title
+			}
+			if target != e && !linked[target] {
+				linked[target] = true
+				target.Data.Backlinks = append(target.Data.Backlinks, s.postItem(e))
+			}
+		})
+	}
+	return errors.Join(errs...)
+}
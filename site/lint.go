@@ -0,0 +1,81 @@
+package site
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"be/component"
+)
+
+// Lint loads all entries and reports every mistake found in them, instead
+// of stopping at the first one like Build does.
+func (s *Site) Lint() []error {
+	if err := s.Load(); err != nil {
+		return []error{err}
+	}
+	var errs []error
+	if err := s.resolveLinks(); err != nil {
+		errs = append(errs, unjoin(err)...)
+	}
+	for _, e := range s.Entries {
+		errs = append(errs, s.lintEntry(e)...)
+	}
+	return errs
+}
+
+func (s *Site) lintEntry(e *Entry) (errs []error) {
+	report := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", e.Source, fmt.Sprintf(format, args...)))
+	}
+	if strings.TrimSpace(e.Data.Title) == "" {
+		report("missing (title)")
+	}
+	if strings.TrimSpace(e.Data.Meta.Description) == "" {
+		report("missing (description)")
+	}
+	if e.Data.Meta.Published.IsZero() {
+		report("missing (published)")
+	}
+	for _, g := range e.Data.Meta.KeepExif {
+		if _, ok := exifGroups[g]; !ok {
+			report("unknown exif group: %s", g)
+		}
+	}
+	component.Walk(e.Data.Content, func(c component.ContentElement) {
+		img, ok := c.(*component.Image)
+		if !ok {
+			return
+		}
+		if img.Path == "" {
+			report("image without (path)")
+			return
+		}
+		if strings.TrimSpace(img.Alt) == "" && strings.TrimSpace(img.Caption) == "" {
+			report("image without (alt) text or caption: %s", img.Path)
+		}
+		if strings.Contains(img.Path, "://") {
+			return
+		}
+		if _, err := os.Stat(s.imageSource(e, img.Path)); err != nil {
+			report("image not found: %s", img.Path)
+		}
+		if _, ok := s.Config.ImageSizes[img.Size]; img.Size != "" && !ok {
+			report("unknown image size: %s", img.Size)
+		}
+		if _, ok := s.Config.ImageKinds[img.Kind]; img.Kind != "" && !ok {
+			report("unknown image kind: %s", img.Kind)
+		}
+	})
+	return errs
+}
+
+// unjoin splits an error created by errors.Join into its parts.
+func unjoin(err error) []error {
+	var joined interface{ Unwrap() []error }
+	if errors.As(err, &joined) {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
@@ -0,0 +1,77 @@
+package site
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type Mastodon struct {
+	// Server of the account entries are announced from, e.g.
+	// https://mastodon.social. Nothing is announced if empty.
+	Server string `json:"server"`
+	// Access token of the account (with the write:statuses scope), best set
+	// from the environment (BLOG_MASTODON__TOKEN).
+	Token string `json:"token"`
+	// text/template of the status, with .Title, .Description, .URL and
+	// .Hashtags (the tags of the entry, as #tag).
+	Template string `json:"template"`
+	// public, unlisted, private or direct.
+	Visibility string `json:"visibility"`
+}
+
+// postStatus posts the status and returns its url.
+// @from: https://docs.joinmastodon.org/methods/statuses/#create
+func postStatus(cfg Mastodon, e *Entry, status string) (string, error) {
+	if cfg.Token == "" {
+		return "", fmt.Errorf("no access token")
+	}
+	body, err := json.Marshal(map[string]string{
+		"status": status,
+		"visibility": cfg.Visibility,
+		"language": e.Data.Meta.Language,
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(cfg.Server, "/")+"/api/v1/statuses", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+cfg.Token)
+	req.Header.Set("Content-Type", "application/json")
+	// posting again (after a failure to save the state, ...) does not
+	// announce the entry twice
+	sum := sha256.Sum256([]byte(e.Slug))
+	req.Header.Set("Idempotency-Key", hex.EncodeToString(sum[:]))
+	var posted struct {
+		URL string `json:"url"`
+	}
+	if err := doJSON(req, &posted); err != nil {
+		return "", err
+	}
+	return posted.URL, nil
+}
+
+// doJSON sends the request, and decodes the json response into v.
+func doJSON(req *http.Request, v any) error {
+	resp, err := (&http.Client{Timeout: 30 * time.Second}).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	bs, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(bs))
+	}
+	return json.Unmarshal(bs, v)
+}
@@ -0,0 +1,265 @@
+package site
+
+import (
+	"cmp"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/http"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"be/component"
+)
+
+type Newsletter struct {
+	// text/template of the subject of an issue, with .BlogName, .Issue (its
+	// number) and .Posts.
+	Subject string `json:"subject"`
+	// Sender of the issues written to .eml files, the author by default.
+	From string `json:"from"`
+	// File remembering the issues so far and the entries in them, keep it
+	// (e.g. commit it).
+	State string `json:"state"`
+	// Where blog newsletter -push creates the issue as a draft, one of
+	// NewsletterButtondown or NewsletterListmonk.
+	Provider string `json:"provider"`
+	// Url of the api, of the listmonk installation (e.g.
+	// https://lists.vanloo.ch). Buttondown is used at its official url if
+	// empty.
+	API string `json:"api"`
+	// Credentials, best set from the environment (BLOG_NEWSLETTER__TOKEN):
+	//   - buttondown: the api key
+	//   - listmonk: user:token of an api user
+	Token string `json:"token"`
+	// listmonk only: ids of the lists the campaign is sent to.
+	Lists []int `json:"lists"`
+}
+
+const (
+	NewsletterButtondown = "buttondown"
+	NewsletterListmonk = "listmonk"
+)
+
+const defaultNewsletterSubject = `{{.BlogName}}, issue {{.Issue}}`
+
+// Issue of the newsletter, see NextIssue.
+type Issue struct {
+	Number int
+	Subject string
+	Entries []*Entry
+	HTML, Text []byte
+}
+
+type newsletterState struct {
+	Issues int `json:"issues"`
+	Last time.Time `json:"last"`
+	// slugs of the entries in an issue so far
+	Entries []string `json:"entries"`
+}
+
+// NextIssue collects the entries not in any issue so far (of the language,
+// if given, and published after since, if not zero), most recent first, and
+// renders the digest of them.
+// Call it after Load.
+func (s *Site) NextIssue(lang string, since time.Time) (*Issue, error) {
+	state, err := s.loadNewsletterState()
+	if err != nil {
+		return nil, err
+	}
+	issue := &Issue{Number: state.Issues + 1}
+	digest := &component.Digest{
+		BlogName: s.Config.BlogName,
+		URL: s.URL("index.html"),
+		Issue: issue.Number,
+		Language: cmp.Or(lang, s.Config.Language),
+	}
+	for _, e := range s.Entries {
+		meta := e.Data.Meta
+		if !announceable(e) || slices.Contains(state.Entries, e.Slug) || (lang != "" && meta.Language != lang) || !meta.Published.After(since) {
+			continue
+		}
+		issue.Entries = append(issue.Entries, e)
+		digest.Posts = append(digest.Posts, s.postItem(e))
+	}
+	if len(issue.Entries) == 0 {
+		return issue, nil
+	}
+
+	subject, err := template.New("subject").Parse(s.Config.Newsletter.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("newsletter: subject: %w", err)
+	}
+	buf := &bytes.Buffer{}
+	if err := subject.Execute(buf, digest); err != nil {
+		return nil, fmt.Errorf("newsletter: subject: %w", err)
+	}
+	issue.Subject = strings.TrimSpace(buf.String())
+	digest.Subject = issue.Subject
+	buf = &bytes.Buffer{}
+	if err := component.RenderDigest(buf, digest); err != nil {
+		return nil, err
+	}
+	issue.HTML = buf.Bytes()
+
+	text := &strings.Builder{}
+	fmt.Fprintf(text, "%s\n\n", issue.Subject)
+	for _, p := range digest.Posts {
+		fmt.Fprintf(text, "%s\n%s\n", p.Title, p.Published.Format("02 Jan 2006"))
+		if p.Excerpt != "" {
+			fmt.Fprintf(text, "\n%s\n", p.Excerpt)
+		}
+		fmt.Fprintf(text, "\n%s\n\n", p.URL)
+	}
+	fmt.Fprintf(text, "You receive this because you subscribed to %s (%s).\n", digest.BlogName, digest.URL)
+	issue.Text = []byte(text.String())
+	return issue, nil
+}
+
+// EML is the issue as email (with a plain text and an html part), to be
+// opened as a draft in a mail client.
+func (s *Site) EML(issue *Issue) ([]byte, error) {
+	from := s.Config.Newsletter.From
+	if from == "" {
+		from = (&mail.Address{Name: s.Config.Author.Name, Address: s.Config.Author.EMail}).String()
+	}
+	buf := &bytes.Buffer{}
+	mw := multipart.NewWriter(buf)
+	fmt.Fprintf(buf, "From: %s\r\n", from)
+	fmt.Fprintf(buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", issue.Subject))
+	fmt.Fprintf(buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("X-Unsent: 1\r\n") // opened as a draft
+	fmt.Fprintf(buf, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", mw.Boundary())
+	for _, part := range []struct {
+		contentType string
+		data []byte
+	}{{"text/plain", issue.Text}, {"text/html", issue.HTML}} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type": {part.contentType + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qw := quotedprintable.NewWriter(w)
+		if _, err := qw.Write(part.data); err != nil {
+			return nil, err
+		}
+		if err := qw.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// PushIssue creates the issue as a draft at the provider, to be reviewed and
+// sent there, and returns what it was created as.
+func (s *Site) PushIssue(issue *Issue) (string, error) {
+	cfg := s.Config.Newsletter
+	if cfg.Token == "" {
+		return "", fmt.Errorf("newsletter: no token")
+	}
+	var (
+		body any
+		u, auth string
+	)
+	switch cfg.Provider {
+	case NewsletterButtondown:
+		// @from: https://docs.buttondown.com/api-emails-create
+		u = cmp.Or(cfg.API, "https://api.buttondown.email") + "/v1/emails"
+		auth = "Token " + cfg.Token
+		body = map[string]string{
+			"subject": issue.Subject,
+			"body": string(issue.HTML),
+			"status": "draft",
+		}
+	case NewsletterListmonk:
+		// @from: https://listmonk.app/docs/apis/campaigns/
+		if cfg.API == "" {
+			return "", fmt.Errorf("newsletter: no api url of the listmonk installation")
+		}
+		u = strings.TrimSuffix(cfg.API, "/") + "/api/campaigns"
+		auth = "token " + cfg.Token
+		body = map[string]any{
+			"name": issue.Subject,
+			"subject": issue.Subject,
+			"lists": cfg.Lists,
+			"type": "regular",
+			"content_type": "html",
+			"body": string(issue.HTML),
+			"altbody": string(issue.Text),
+		}
+	default:
+		return "", fmt.Errorf("newsletter: invalid provider: %q", cfg.Provider)
+	}
+	bs, err := json.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(bs))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", auth)
+	req.Header.Set("Content-Type", "application/json")
+	var created struct {
+		ID any `json:"id"`
+		Data struct {
+			ID any `json:"id"`
+		} `json:"data"`
+	}
+	if err := doJSON(req, &created); err != nil {
+		return "", fmt.Errorf("newsletter: %s: %w", cfg.Provider, err)
+	}
+	if created.ID == nil {
+		created.ID = created.Data.ID
+	}
+	return fmt.Sprintf("%s draft %v", cfg.Provider, created.ID), nil
+}
+
+// RecordIssue remembers the entries of the issue, they are not in the next
+// one.
+func (s *Site) RecordIssue(issue *Issue) error {
+	state, err := s.loadNewsletterState()
+	if err != nil {
+		return err
+	}
+	state.Issues = issue.Number
+	state.Last = time.Now()
+	for _, e := range issue.Entries {
+		state.Entries = append(state.Entries, e.Slug)
+	}
+	bs, err := json.MarshalIndent(state, "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeCache(s.Config.Newsletter.State, append(bs, '\n'))
+}
+
+func (s *Site) loadNewsletterState() (newsletterState, error) {
+	var state newsletterState
+	bs, err := os.ReadFile(s.Config.Newsletter.State)
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, nil
+	} else if err != nil {
+		return state, err
+	}
+	if err := json.Unmarshal(bs, &state); err != nil {
+		return state, fmt.Errorf("%s: %w", s.Config.Newsletter.State, err)
+	}
+	return state, nil
+}
@@ -0,0 +1,39 @@
+package site
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"be/component"
+)
+
+const notFoundRecentPosts = 5
+
+// buildNotFound renders the 404 page like any other entry, followed by a list
+// of recent posts and a search box.
+func (s *Site) buildNotFound() error {
+	e, err := LoadEntry(s.Config.NotFound)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	s.applyDefaults(e)
+	e.Data.Meta.NoIndex = true
+	e.Data.Content = append(e.Data.Content,
+		component.SearchBox{},
+		component.PostList{
+			Title: "Recent Posts",
+			Posts: s.Recent(s.Config.Language, notFoundRecentPosts),
+		},
+	)
+	buf := &bytes.Buffer{}
+	if err := component.RenderEntry(buf, e.Data); err != nil {
+		return fmt.Errorf("%s: %w", e.Source, err)
+	}
+	s.Emit("404.html", buf.Bytes())
+	return nil
+}
@@ -0,0 +1,139 @@
+package site
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/jpeg"
+	"image/png"
+	"os"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Size recommended for og:image / twitter:image:summary_large_image.
+const (
+	previewWidth = 1200
+	previewHeight = 630
+	previewMargin = 80
+)
+
+var (
+	// same as --fb-voidSteel, --fb-voidBlack and --fb-voidGreen in themes/light.css
+	previewBg = color.RGBA{0xD0, 0xD0, 0xD0, 0xFF}
+	previewFg = color.RGBA{0x12, 0x12, 0x12, 0xFF}
+	previewAccent = color.RGBA{0x56, 0x9F, 0x7A, 0xFF}
+)
+
+func previewPath(e *Entry) string {
+	return "public/preview/" + e.Slug + ".png"
+}
+
+// buildPreviewImage renders the title of the entry and the blog name onto
+// the configured template (or a plain background if there is none).
+func (s *Site) buildPreviewImage(e *Entry) error {
+	var tmpl []byte
+	if s.Config.PreviewTemplate != "" {
+		var err error
+		if tmpl, err = os.ReadFile(s.Config.PreviewTemplate); err != nil {
+			return err
+		}
+	}
+	title, brand := e.Data.Title, "("+s.Config.BlogName+")"
+	data, err := s.cached("previews", func() ([]byte, error) {
+		return renderPreviewImage(title, brand, tmpl)
+	}, []byte(title), []byte(brand), tmpl)
+	if err != nil {
+		return err
+	}
+	s.Emit(previewPath(e), data)
+	return nil
+}
+
+// renderPreviewImage renders the title and brand as png, onto the template
+// image if there is one.
+func renderPreviewImage(title, brand string, tmpl []byte) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, previewWidth, previewHeight))
+	draw.Draw(img, img.Bounds(), image.NewUniform(previewBg), image.Point{}, draw.Src)
+	if tmpl != nil {
+		t, _, err := image.Decode(bytes.NewReader(tmpl))
+		if err != nil {
+			return nil, err
+		}
+		draw.Draw(img, img.Bounds(), t, t.Bounds().Min, draw.Over)
+	}
+
+	titleFace, err := loadFace(gobold.TTF, 72)
+	if err != nil {
+		return nil, err
+	}
+	defer titleFace.Close()
+	brandFace, err := loadFace(gomono.TTF, 36)
+	if err != nil {
+		return nil, err
+	}
+	defer brandFace.Close()
+
+	d := &font.Drawer{
+		Dst: img,
+		Src: image.NewUniform(previewFg),
+		Face: titleFace,
+	}
+	lineHeight := titleFace.Metrics().Height.Ceil()
+	y := previewMargin + titleFace.Metrics().Ascent.Ceil()
+	for _, line := range wrapText(d, title, previewWidth-2*previewMargin) {
+		if y > previewHeight-2*previewMargin {
+			break // doesn't fit, cut off
+		}
+		d.Dot = fixed.P(previewMargin, y)
+		d.DrawString(line)
+		y += lineHeight
+	}
+
+	d.Face = brandFace
+	d.Src = image.NewUniform(previewAccent)
+	d.Dot = fixed.P(previewMargin, previewHeight-previewMargin)
+	d.DrawString(brand)
+
+	buf := &bytes.Buffer{}
+	err = png.Encode(buf, img)
+	return buf.Bytes(), err
+}
+
+func loadFace(ttf []byte, size float64) (font.Face, error) {
+	f, err := opentype.Parse(ttf)
+	if err != nil {
+		return nil, err
+	}
+	return opentype.NewFace(f, &opentype.FaceOptions{
+		Size: size,
+		DPI: 72,
+		Hinting: font.HintingFull,
+	})
+}
+
+// wrapText breaks text into lines no wider than width pixels.
+func wrapText(d *font.Drawer, text string, width int) (lines []string) {
+	line := ""
+	for _, word := range strings.Fields(text) {
+		next := word
+		if line != "" {
+			next = line + " " + word
+		}
+		if line != "" && d.MeasureString(next).Ceil() > width {
+			lines = append(lines, line)
+			next = word
+		}
+		line = next
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
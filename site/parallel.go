@@ -0,0 +1,36 @@
+package site
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+)
+
+// parallel calls fn for each of n items, on at most GOMAXPROCS goroutines at
+// a time. The errors of all items are joined, in the order of the items.
+func parallel(n int, fn func(i int) error) error {
+	return parallelN(runtime.GOMAXPROCS(0), n, fn)
+}
+
+// parallelN is parallel on at most workers goroutines, for work that is not
+// bound by the cpu (e.g. requests to other servers).
+func parallelN(workers, n int, fn func(i int) error) error {
+	errs := make([]error, n)
+	next := make(chan int)
+	wg := sync.WaitGroup{}
+	for range min(max(workers, 1), n) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				errs[i] = fn(i)
+			}
+		}()
+	}
+	for i := range n {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return errors.Join(errs...)
+}
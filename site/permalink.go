@@ -0,0 +1,52 @@
+package site
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var placeholderPattern = regexp.MustCompile(`:([a-z]+)`)
+
+// permalink fills in the placeholders of the permalink template (see
+// Config.Permalink) for the entry, and returns the path its page is emitted
+// to.
+func (s *Site) permalink(e *Entry) (string, error) {
+	tmpl := s.Config.Permalink
+	if tmpl == "" {
+		tmpl = defaultPermalink
+	}
+	published := e.Data.Meta.Published
+	var err error
+	p := placeholderPattern.ReplaceAllStringFunc(tmpl, func(m string) string {
+		name := m[1:]
+		switch name {
+		case "slug":
+			return e.Slug
+		case "lang":
+			return e.Data.Meta.Language
+		case "year", "month", "day":
+			if published.IsZero() {
+				err = fmt.Errorf("permalink %s uses :%s, but the entry has no publishing date", tmpl, name)
+				return m
+			}
+			return map[string]string{
+				"year": published.Format("2006"),
+				"month": published.Format("01"),
+				"day": published.Format("02"),
+			}[name]
+		}
+		err = fmt.Errorf("permalink %s: unknown placeholder :%s", tmpl, name)
+		return m
+	})
+	if err != nil {
+		return "", err
+	}
+	// directories, with or without the trailing slash, are served their
+	// index.html
+	if strings.HasSuffix(p, "/") || path.Ext(p) == "" {
+		p = path.Join(p, "index.html")
+	}
+	return strings.TrimPrefix(path.Clean("/"+p), "/"), nil
+}
@@ -0,0 +1,272 @@
+package site
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Number of releases kept in the releases directory, the one served and the
+// ones before it (to go back to by hand).
+const keepReleases = 3
+
+// Publisher serves the output directory in production, and keeps it up to
+// date with the git repository the site lives in: a webhook (e.g. called by
+// the git host on every push) has it pull, rebuild and swap in the new
+// output.
+//
+// The output directory is a symlink to the current release, a directory in
+// <output>.releases. Replacing the symlink is atomic, requests are never
+// served a half written site.
+type Publisher struct {
+	configPath, profile string
+	// shared with the git host, authenticates the webhook calls
+	secret string
+	// one update at a time
+	update sync.Mutex
+	mu sync.Mutex
+	// an update waits for the running one, more are not needed
+	queued bool
+	output string
+}
+
+// NewPublisher publishes the site built from the config at configPath, with
+// the named profile applied (see LoadConfig).
+func NewPublisher(configPath, profile, secret string) *Publisher {
+	return &Publisher{configPath: configPath, profile: profile, secret: secret}
+}
+
+// Update pulls the repository (if pull), builds the site into a new release
+// (running the build hooks) and makes it the current one.
+func (p *Publisher) Update(pull bool) error {
+	p.update.Lock()
+	defer p.update.Unlock()
+	return p.publish(pull)
+}
+
+func (p *Publisher) publish(pull bool) error {
+	if pull {
+		// git needs to run in the repository, which the config is part of
+		cmd := exec.Command("git", "pull", "--ff-only")
+		cmd.Dir = filepath.Dir(p.configPath)
+		if err := run(cmd); err != nil {
+			return err
+		}
+	}
+	cfg, err := LoadConfig(p.configPath, p.profile)
+	if err != nil {
+		return err
+	}
+	output := filepath.Clean(cfg.Output)
+	releases := output + ".releases"
+	release := filepath.Join(releases, time.Now().UTC().Format("20060102T150405.000"))
+	cfg.Output = release
+	s := New(cfg)
+	if err := s.RunHooks(HookPreBuild, ""); err != nil {
+		return err
+	}
+	if err := s.Build(); err != nil {
+		return err
+	}
+	if err := s.Write(); err != nil {
+		return err
+	}
+	// on the release, before it is served
+	if err := s.RunHooks(HookPostBuild, ""); err != nil {
+		return err
+	}
+	if err := swapOutput(output, release); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.output = output
+	p.mu.Unlock()
+	s.pruneReleases(releases)
+	slog.Info("published", "release", release, "entries", len(s.Entries))
+	return nil
+}
+
+// swapOutput points the output symlink at the release. An output directory
+// that is not yet a symlink becomes the first release.
+func swapOutput(output, release string) error {
+	fi, err := os.Lstat(output)
+	if err == nil && fi.Mode()&fs.ModeSymlink == 0 {
+		if err := os.Rename(output, filepath.Join(filepath.Dir(release), "initial")); err != nil {
+			return err
+		}
+	} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	target, err := filepath.Rel(filepath.Dir(output), release)
+	if err != nil {
+		return err
+	}
+	next := output + ".next"
+	os.Remove(next) // left behind by an interrupted swap
+	if err := os.Symlink(target, next); err != nil {
+		return err
+	}
+	return os.Rename(next, output)
+}
+
+// pruneReleases removes all but the most recent releases, and forgets what
+// was written into them.
+func (s *Site) pruneReleases(releases string) {
+	entries, err := os.ReadDir(releases)
+	if err != nil {
+		return
+	}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		// named by time, "initial" is older than all of them
+		return names[j] != "initial" && (names[i] == "initial" || names[i] < names[j])
+	})
+	for _, name := range names[:max(0, len(names)-keepReleases)] {
+		dir := filepath.Join(releases, name)
+		if err := os.RemoveAll(dir); err != nil {
+			slog.Warn("cannot remove old release", "release", dir, "err", err)
+			continue
+		}
+		s.forgetWritten(dir)
+	}
+}
+
+// ServeHTTP starts an update on webhook calls (POST /_webhook), and serves
+// the current release otherwise.
+func (p *Publisher) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == webhookPath {
+		p.serveWebhook(w, r)
+		return
+	}
+	p.mu.Lock()
+	output := p.output
+	p.mu.Unlock()
+	if output == "" {
+		http.Error(w, "site not built yet", http.StatusServiceUnavailable)
+		return
+	}
+	// resolved for every request, so that a swapped release is served right
+	// away
+	f := path.Clean("/" + r.URL.Path)
+	if _, err := os.Stat(filepath.Join(output, filepath.FromSlash(f))); errors.Is(err, fs.ErrNotExist) {
+		notFound, err := os.ReadFile(filepath.Join(output, "404.html"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write(notFound)
+		return
+	}
+	http.FileServer(http.Dir(output)).ServeHTTP(w, r)
+}
+
+const webhookPath = "/_webhook"
+
+func (p *Publisher) serveWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !p.authorized(r, body) {
+		slog.Warn("unauthorized webhook call", "remote", r.RemoteAddr)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintln(w, "update started")
+	go p.updateSoon()
+}
+
+// updateSoon updates in the background. Calls that arrive while an update
+// runs result in a single update after it.
+func (p *Publisher) updateSoon() {
+	p.mu.Lock()
+	if p.queued {
+		p.mu.Unlock()
+		return
+	}
+	p.queued = true
+	p.mu.Unlock()
+	p.update.Lock()
+	defer p.update.Unlock()
+	p.mu.Lock()
+	p.queued = false
+	p.mu.Unlock()
+	if err := p.publish(true); err != nil {
+		slog.Error("update failed", "err", err)
+	}
+}
+
+// authorized checks the webhook call was made by someone who knows the
+// secret, the way the common git hosts sign their calls:
+//   - GitHub, Gitea, Forgejo: X-Hub-Signature-256, hmac of the body
+//   - Gitea (older versions): X-Gitea-Signature, same without prefix
+//   - GitLab: X-Gitlab-Token, the secret itself
+//   - anything else: Authorization: Bearer <secret>
+func (p *Publisher) authorized(r *http.Request, body []byte) bool {
+	if p.secret == "" {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(p.secret))
+	mac.Write(body)
+	sum := hex.EncodeToString(mac.Sum(nil))
+	equal := func(a, b string) bool {
+		return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+	}
+	if sig := r.Header.Get("X-Hub-Signature-256"); sig != "" {
+		return equal(sig, "sha256="+sum)
+	}
+	if sig := r.Header.Get("X-Gitea-Signature"); sig != "" {
+		return equal(sig, sum)
+	}
+	if token := r.Header.Get("X-Gitlab-Token"); token != "" {
+		return equal(token, p.secret)
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return equal(token, p.secret)
+	}
+	return false
+}
+
+// ListenAndServe builds the site (without pulling first), and serves it at
+// addr while waiting for webhook calls.
+func (p *Publisher) ListenAndServe(addr string) error {
+	if p.secret == "" {
+		return fmt.Errorf("no webhook secret set")
+	}
+	if err := p.Update(false); err != nil {
+		return err
+	}
+	slog.Info("serving", "url", "http://"+addr, "webhook", webhookPath)
+	return http.ListenAndServe(addr, p)
+}
+
+var _ http.Handler = (*Publisher)(nil)
@@ -0,0 +1,77 @@
+package site
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestWebhookAuthorized(t *testing.T) {
+	const secret = "secret"
+	body := []byte(`{"ref":"refs/heads/main"}`)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	sum := hex.EncodeToString(mac.Sum(nil))
+	for _, tt := range []struct {
+		name string
+		header, value string
+		ok bool
+	}{
+		{"github", "X-Hub-Signature-256", "sha256=" + sum, true},
+		{"github, other body", "X-Hub-Signature-256", "sha256=" + strings.Repeat("0", len(sum)), false},
+		{"gitea", "X-Gitea-Signature", sum, true},
+		{"gitlab", "X-Gitlab-Token", secret, true},
+		{"gitlab, wrong token", "X-Gitlab-Token", "guess", false},
+		{"bearer", "Authorization", "Bearer " + secret, true},
+		{"none", "", "", false},
+	} {
+		r := httptest.NewRequest(http.MethodPost, webhookPath, nil)
+		if tt.header != "" {
+			r.Header.Set(tt.header, tt.value)
+		}
+		if ok := (&Publisher{secret: secret}).authorized(r, body); ok != tt.ok {
+			t.Errorf("%s: authorized %v, want %v", tt.name, ok, tt.ok)
+		}
+	}
+	r := httptest.NewRequest(http.MethodPost, webhookPath, nil)
+	r.Header.Set("X-Gitlab-Token", "")
+	if (&Publisher{}).authorized(r, body) {
+		t.Error("authorized without a secret")
+	}
+}
+
+func TestSwapOutputOrphans(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "build")
+	release := filepath.Join(dir, "build.releases", "r1")
+	if err := os.MkdirAll(release, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"index.html", "stale.html"} {
+		if err := os.WriteFile(filepath.Join(release, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := swapOutput(output, release); err != nil {
+		t.Fatal(err)
+	}
+	s := New(Config{Output: output})
+	s.outputs = map[string][]byte{"index.html": nil}
+	removed, err := s.RemoveOrphans()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(removed, []string{"stale.html"}) {
+		t.Errorf("removed %v, want the stale page only", removed)
+	}
+	if fi, err := os.Lstat(output); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("output symlink gone: %v", err)
+	}
+}
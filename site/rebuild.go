@@ -0,0 +1,264 @@
+package site
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"be/component"
+)
+
+const pollInterval = 500 * time.Millisecond
+
+// Rebuild updates only the outputs affected by changes to the given files
+// (modified, added or removed), instead of building the whole site again:
+//   - an entry source: its page, the pages of the entries related to it
+//     (see related), and everything made up of all entries (indexes, feeds,
+//     tags, ...)
+//   - a public file: its copy
+//   - the not found page: itself (along with the other aggregates)
+//
+// Any other change (the theme, the favicon, a bundled stylesheet, an image
+// used by some entry, ...) results in a full build.
+// Pages including partials that list the entries (see component.SiteData)
+// are only updated if they are rebuilt themselves.
+// Changes to the config are not noticed, build a New site instead.
+func (s *Site) Rebuild(changed []string) error {
+	s.head = gitHead()
+	var sources, public []string
+	for _, p := range changed {
+		p = filepath.Clean(p)
+		switch {
+		case p == filepath.Clean(s.Config.NotFound):
+		case filepath.Ext(p) == ".be" && within(s.Config.Sources, p):
+			sources = append(sources, p)
+		case within(s.Config.Public, p) && !s.publicBundleSource(p) && s.images[p] == nil:
+			public = append(public, p)
+		default:
+			slog.Debug("change not traceable, rebuilding everything", "path", p)
+			return s.Build()
+		}
+	}
+	s.stats = newBuildStats()
+	defer s.stats.done()
+	if err := s.rebuildEntries(sources); err != nil {
+		return err
+	}
+	done := s.stats.timed("assets")
+	for _, p := range public {
+		if err := s.copyPublicFile(p); err != nil {
+			done()
+			return err
+		}
+	}
+	done()
+	return s.buildAggregates()
+}
+
+// within reports whether p is somewhere below the directory dir.
+func within(dir, p string) bool {
+	rel, err := filepath.Rel(dir, p)
+	return err == nil && rel != "." && !strings.HasPrefix(rel, "..")
+}
+
+func (s *Site) publicBundleSource(p string) bool {
+	rel, err := filepath.Rel(s.Config.Public, p)
+	return err == nil && s.bundleSource(filepath.ToSlash(rel))
+}
+
+// rebuildEntries reloads the changed entry sources along with the entries
+// related to either their old or their new version, and renders them again.
+func (s *Site) rebuildEntries(sources []string) error {
+	if len(sources) == 0 {
+		return nil
+	}
+	old := map[string]*Entry{}
+	for _, e := range s.Entries {
+		old[e.Source] = e
+	}
+	affected := map[string]bool{}
+	loaded := map[string]*Entry{}
+	for _, src := range sources {
+		affected[src] = true
+		if e, ok := old[src]; ok {
+			for _, r := range s.related(e) {
+				affected[r.Source] = true
+			}
+		}
+		e, err := s.reloadEntry(src)
+		if err != nil {
+			return err
+		}
+		if e == nil {
+			continue
+		}
+		loaded[src] = e
+		for _, r := range s.related(e) {
+			affected[r.Source] = true
+		}
+	}
+
+	var entries, rebuilt []*Entry
+	for _, e := range s.Entries {
+		if !affected[e.Source] {
+			entries = append(entries, e)
+		}
+	}
+	for src := range affected {
+		e, ok := loaded[src]
+		if !ok {
+			var err error
+			if e, err = s.reloadEntry(src); err != nil {
+				return err
+			}
+		}
+		if e == nil {
+			continue
+		}
+		entries = append(entries, e)
+		rebuilt = append(rebuilt, e)
+	}
+	// Take back what the old versions emitted before building the new ones,
+	// an entry may have been moved, or removed, and another one built to its
+	// path instead. What is still there is emitted again.
+	for src := range affected {
+		if o := old[src]; o != nil {
+			for _, p := range s.entryOutputs(o) {
+				s.remove(p)
+			}
+		}
+	}
+	slog.Debug("rebuilding entries", "changed", len(sources), "affected", len(affected))
+	s.Entries = entries
+	s.sortEntries()
+	if err := s.checkPermalinks(); err != nil {
+		return err
+	}
+	s.linkTranslations()
+	if err := s.resolveLinks(); err != nil {
+		return err
+	}
+	s.setSiteData()
+	return parallel(len(rebuilt), func(i int) error {
+		return s.buildEntry(rebuilt[i])
+	})
+}
+
+// entryOutputs lists the files emitted for the entry itself: its page, the
+// generated preview image and the redirect stubs of its aliases.
+func (s *Site) entryOutputs(e *Entry) []string {
+	ps := []string{e.Path(), previewPath(e)}
+	if s.Config.Redirects == RedirectMeta {
+		for _, alias := range e.Data.Aliases {
+			ps = append(ps, aliasStub(alias))
+		}
+	}
+	return ps
+}
+
+// reloadEntry returns nil if the source was removed, or is no longer
+// included (see included).
+func (s *Site) reloadEntry(source string) (*Entry, error) {
+	e, err := s.loadEntry(source)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil || !s.included(e) {
+		return nil, err
+	}
+	return e, nil
+}
+
+// related returns the other entries whose pages depend on e, or on whose
+// pages e depends: the entries linking to e (the link text defaults to its
+// title), those e links to (it is one of their backlinks), and its
+// translations (they link each other).
+// This is the dependency graph between entries, the indexes, feeds, etc.
+// depend on all of them anyway.
+func (s *Site) related(e *Entry) []*Entry {
+	refs := linkRefs(e)
+	var rs []*Entry
+	for _, o := range s.Entries {
+		if o.Source == e.Source {
+			continue
+		}
+		if refs[o.Slug] || linkRefs(o)[e.Slug] || translationKey(o) == translationKey(e) {
+			rs = append(rs, o)
+		}
+	}
+	return rs
+}
+
+// linkRefs returns the slugs of the entries e links to.
+func linkRefs(e *Entry) map[string]bool {
+	refs := map[string]bool{}
+	component.Walk(e.Data.Content, func(c component.ContentElement) {
+		if link, ok := c.(*component.Link); ok && link.Ref != "" {
+			refs[link.Ref] = true
+		}
+	})
+	return refs
+}
+
+// Watched lists the files and directories the site is built from, except
+// for the config itself.
+func (s *Site) Watched() []string {
+	watched := []string{s.Config.Sources, s.Config.Public, s.Config.NotFound, s.Config.Favicon, s.Config.Themes.Light.Palette, s.Config.Themes.Dark.Palette}
+	if s.Config.Theme != "" {
+		watched = append(watched, s.Config.Theme)
+	}
+	return watched
+}
+
+// Watch polls the files below the watched paths, and calls changed with
+// those modified, added or removed since the last time. It never returns.
+// watched is asked again before every poll, it may change along with the
+// config.
+func Watch(watched func() []string, changed func([]string)) {
+	last := snapshot(watched())
+	for range time.Tick(pollInterval) {
+		now := snapshot(watched())
+		var ps []string
+		for p, stamp := range now {
+			if last[p] != stamp {
+				ps = append(ps, p)
+			}
+		}
+		for p := range last {
+			if _, ok := now[p]; !ok {
+				ps = append(ps, p)
+			}
+		}
+		last = now
+		if len(ps) > 0 {
+			slices.Sort(ps)
+			changed(ps)
+		}
+	}
+}
+
+// snapshot maps all files below the roots to their modification time and
+// size, any change to a file changes its stamp.
+func snapshot(roots []string) map[string]string {
+	stamps := map[string]string{}
+	for _, root := range roots {
+		if root == "" {
+			continue
+		}
+		filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil // not (yet) there
+			}
+			if info, err := d.Info(); err == nil {
+				stamps[filepath.Clean(p)] = fmt.Sprintf("%d %d", info.ModTime().UnixNano(), info.Size())
+			}
+			return nil
+		})
+	}
+	return stamps
+}
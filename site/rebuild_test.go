@@ -0,0 +1,74 @@
+package site
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestRebuildMovedEntry(t *testing.T) {
+	dir := t.TempDir()
+	write := func(p, src string) {
+		t.Helper()
+		p = filepath.Join(dir, p)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("entries/a.be", "(title A)\n(published 2024-01-02)\n(aliases old-a.html)\n(body\nA\n)\n")
+	write("entries/b.be", "(title B)\n(published 2024-01-03)\n(body\nB\n)\n")
+	write("public/styles.css", "p {}")
+
+	cfg := DefaultConfig()
+	cfg.Dev = true
+	cfg.Sources = filepath.Join(dir, "entries")
+	cfg.Public = filepath.Join(dir, "public")
+	cfg.NotFound = filepath.Join(dir, "404.be")
+	cfg.Output = filepath.Join(dir, "build")
+	cfg.Cache = filepath.Join(dir, ".cache")
+	cfg.Bundles = nil
+	cfg.Favicon = ""
+	cfg.Themes.Light.Palette = "../themes/light.css"
+	cfg.Themes.Dark.Palette = "../themes/dark.css"
+	s := New(cfg)
+	if err := s.Build(); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"a.html", "old-a.html", "public/preview/a.png"} {
+		if !slices.Contains(s.Outputs(), p) {
+			t.Fatalf("%s not built: %q", p, s.Outputs())
+		}
+	}
+
+	// moved to another directory, keeping its slug (and thus its path)
+	if err := os.Remove(filepath.Join(dir, "entries/a.be")); err != nil {
+		t.Fatal(err)
+	}
+	write("entries/2024/a.be", "(title A)\n(published 2024-01-02)\n(body\nA\n)\n")
+	// removed, along with its page, preview and alias stub
+	write("entries/b.be", "(title B)\n(published 2024-01-03)\n(aliases old-b.html)\n(body\nB\n)\n")
+	if err := s.Rebuild([]string{filepath.Join(dir, "entries/a.be"), filepath.Join(dir, "entries/2024/a.be"), filepath.Join(dir, "entries/b.be")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "entries/b.be")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Rebuild([]string{filepath.Join(dir, "entries/b.be")}); err != nil {
+		t.Fatal(err)
+	}
+	outputs := s.Outputs()
+	for _, p := range []string{"a.html", "public/preview/a.png"} {
+		if !slices.Contains(outputs, p) {
+			t.Errorf("%s of the moved entry taken back", p)
+		}
+	}
+	for _, p := range []string{"old-a.html", "b.html", "old-b.html", "public/preview/b.png"} {
+		if slices.Contains(outputs, p) {
+			t.Errorf("%s left over", p)
+		}
+	}
+}
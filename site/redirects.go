@@ -0,0 +1,47 @@
+package site
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+
+	"be/component"
+)
+
+// buildRedirects emits the stub pages, or collects the rules of the web
+// server config (see buildServerConfig).
+func (s *Site) buildRedirects() error {
+	var rules []string
+	for _, e := range s.Entries {
+		target := Canonical(e.Path())
+		for _, alias := range e.Data.Aliases {
+			from := Canonical(alias)
+			switch s.Config.Redirects {
+			case RedirectMeta:
+				buf := &bytes.Buffer{}
+				if err := component.RenderRedirect(buf, s.URL(target)); err != nil {
+					return fmt.Errorf("%s: %w", e.Source, err)
+				}
+				s.Emit(aliasStub(alias), buf.Bytes())
+			case RedirectNetlify:
+				rules = append(rules, fmt.Sprintf("%s %s 301", from, target))
+			case RedirectHtaccess:
+				rules = append(rules, fmt.Sprintf("Redirect 301 %s %s", from, target))
+			default:
+				return fmt.Errorf("invalid redirects option: %s", s.Config.Redirects)
+			}
+		}
+	}
+	s.redirectRules = rules
+	return nil
+}
+
+// aliasStub is the path of the page redirecting from the alias.
+func aliasStub(alias string) string {
+	stub := Canonical(alias)
+	if strings.HasSuffix(stub, "/") {
+		stub = path.Join(stub, "index.html")
+	}
+	return strings.TrimPrefix(stub, "/")
+}
@@ -0,0 +1,46 @@
+package site
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"be/component"
+)
+
+func TestBuildRedirects(t *testing.T) {
+	entries := []*Entry{
+		{Slug: "new", Data: &component.EntryData{Aliases: []string{"old.html", "older/"}}},
+	}
+	for _, tt := range []struct {
+		format string
+		rules []string
+		stubs []string
+	}{
+		{RedirectMeta, nil, []string{"old.html", "older/index.html"}},
+		{RedirectNetlify, []string{"/old.html /new.html 301", "/older/ /new.html 301"}, nil},
+		{RedirectHtaccess, []string{"Redirect 301 /old.html /new.html", "Redirect 301 /older/ /new.html"}, nil},
+	} {
+		s := New(Config{BaseURL: "https://example.org", Redirects: tt.format})
+		s.Entries = entries
+		if err := s.buildRedirects(); err != nil {
+			t.Fatalf("%s: %v", tt.format, err)
+		}
+		if !slices.Equal(s.redirectRules, tt.rules) {
+			t.Errorf("%s: rules %q, want %q", tt.format, s.redirectRules, tt.rules)
+		}
+		if got := s.Outputs(); !slices.Equal(got, tt.stubs) {
+			t.Errorf("%s: stubs %q, want %q", tt.format, got, tt.stubs)
+		}
+		for _, p := range tt.stubs {
+			if !strings.Contains(string(s.outputs[p]), "https://example.org/new.html") {
+				t.Errorf("%s: %s does not redirect to the entry:\n%s", tt.format, p, s.outputs[p])
+			}
+		}
+	}
+	s := New(Config{Redirects: "nginx"})
+	s.Entries = entries
+	if err := s.buildRedirects(); err == nil {
+		t.Error("invalid redirects option accepted")
+	}
+}
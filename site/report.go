@@ -0,0 +1,150 @@
+package site
+
+import (
+	"path"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Stages of a build, in the order they run.
+// The entries are tokenized, parsed, have their images processed and are
+// rendered in parallel.
+var stages = []string{"assets", "tokenize", "parse", "images", "render", "aggregates", "postprocess", "write"}
+
+// buildStats collects the timings and cache lookups of a build.
+// A nil *buildStats collects nothing.
+type buildStats struct {
+	mu sync.Mutex
+	start time.Time
+	duration time.Duration
+	stages map[string]time.Duration
+	hits, misses map[string]int
+}
+
+func newBuildStats() *buildStats {
+	return &buildStats{
+		start: time.Now(),
+		stages: map[string]time.Duration{},
+		hits: map[string]int{},
+		misses: map[string]int{},
+	}
+}
+
+// timed measures a stage, call the returned function when it is done:
+//
+//	defer st.timed("render")()
+func (st *buildStats) timed(stage string) func() {
+	if st == nil {
+		return func() {}
+	}
+	start := time.Now()
+	return func() {
+		st.mu.Lock()
+		defer st.mu.Unlock()
+		st.stages[stage] += time.Since(start)
+	}
+}
+
+func (st *buildStats) lookup(kind string, hit bool) {
+	if st == nil {
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if hit {
+		st.hits[kind]++
+	} else {
+		st.misses[kind]++
+	}
+}
+
+func (st *buildStats) done() {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.duration = time.Since(st.start)
+}
+
+type (
+	// Report summarizes the last build (or rebuild) of the site.
+	Report struct {
+		Entries int `json:"entries"`
+		// Html pages among the outputs.
+		Pages int `json:"pages"`
+		Outputs int `json:"outputs"`
+		// Of all outputs, in bytes.
+		Size int `json:"size"`
+		Largest []OutputSize `json:"largest"`
+		// Of the build, not including writing the outputs.
+		Duration time.Duration `json:"duration_ns"`
+		// Time spent per stage, in the order they ran. The time of the stages
+		// run in parallel is summed up, and can exceed the whole build.
+		Stages []StageTime `json:"stages"`
+		// Lookups of the build cache by kind of result (lastmod, preview,
+		// minify, ...).
+		Cache map[string]CacheLookups `json:"cache"`
+		// Share of all lookups that were hits, from 0 to 1.
+		CacheHitRate float64 `json:"cache_hit_rate"`
+	}
+
+	OutputSize struct {
+		Path string `json:"path"`
+		Size int `json:"size"`
+	}
+
+	StageTime struct {
+		Stage string `json:"stage"`
+		Duration time.Duration `json:"duration_ns"`
+	}
+
+	CacheLookups struct {
+		Hits int `json:"hits"`
+		Misses int `json:"misses"`
+	}
+)
+
+// Report summarizes the last build, with the n largest outputs.
+func (s *Site) Report(n int) Report {
+	st := s.stats
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	r := Report{
+		Entries: len(s.Entries),
+		Duration: st.duration,
+		Cache: map[string]CacheLookups{},
+	}
+	var sizes []OutputSize
+	for _, p := range s.Outputs() {
+		size := len(s.outputs[p])
+		r.Outputs++
+		r.Size += size
+		if path.Ext(p) == ".html" {
+			r.Pages++
+		}
+		sizes = append(sizes, OutputSize{p, size})
+	}
+	sort.SliceStable(sizes, func(i, j int) bool {
+		return sizes[i].Size > sizes[j].Size
+	})
+	r.Largest = sizes[:min(n, len(sizes))]
+	for _, stage := range stages {
+		if d, ok := st.stages[stage]; ok {
+			r.Stages = append(r.Stages, StageTime{stage, d})
+		}
+	}
+	hits, lookups := 0, 0
+	for kind, n := range st.hits {
+		r.Cache[kind] = CacheLookups{Hits: n, Misses: st.misses[kind]}
+	}
+	for kind, n := range st.misses {
+		r.Cache[kind] = CacheLookups{Hits: st.hits[kind], Misses: n}
+	}
+	for _, c := range r.Cache {
+		hits += c.Hits
+		lookups += c.Hits + c.Misses
+	}
+	if lookups > 0 {
+		r.CacheHitRate = float64(hits) / float64(lookups)
+	}
+	return r
+}
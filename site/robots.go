@@ -0,0 +1,15 @@
+package site
+
+import (
+	"fmt"
+	"strings"
+)
+
+func (s *Site) buildRobots() {
+	sb := &strings.Builder{}
+	fmt.Fprintln(sb, "User-agent: *")
+	fmt.Fprintln(sb, "Allow: /")
+	fmt.Fprintln(sb)
+	fmt.Fprintf(sb, "Sitemap: %s\n", s.URL("sitemap.xml"))
+	s.Emit("robots.txt", []byte(sb.String()))
+}
@@ -0,0 +1,87 @@
+package site
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode"
+
+	"be/component"
+)
+
+type (
+	// searchIndex is deliberately terse, it is downloaded by every reader
+	// that searches.
+	searchIndex struct {
+		Docs []searchDoc `json:"docs"`
+		// term => ids of the docs containing it
+		Terms map[string][]int `json:"terms"`
+	}
+	searchDoc struct {
+		Title string `json:"t"`
+		URL string `json:"u"`
+		Date string `json:"d,omitempty"`
+		Tags []string `json:"g"`
+	}
+)
+
+const searchIndexPath = "search.json"
+
+// searchTerms must split text the same way as the tokenize function of the
+// SearchResults script does.
+func searchTerms(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	terms := words[:0]
+	for _, w := range words {
+		if len([]rune(w)) > 1 {
+			terms = append(terms, w)
+		}
+	}
+	return terms
+}
+
+func (s *Site) buildSearch() error {
+	index := searchIndex{
+		Docs: []searchDoc{},
+		Terms: map[string][]int{},
+	}
+	for id, e := range s.Entries {
+		doc := searchDoc{
+			Title: e.Data.Title,
+			URL: Canonical(e.Path()),
+			Tags: []string{},
+		}
+		if !e.Data.Meta.Published.IsZero() {
+			doc.Date = e.Data.Meta.Published.Format("02 Jan 2006")
+		}
+		text := []string{e.Data.Title, e.Data.Meta.Description, e.Data.PlainText()}
+		for _, t := range e.Data.Tags {
+			doc.Tags = append(doc.Tags, strings.ToLower(t.Name()))
+			text = append(text, t.Name())
+		}
+		index.Docs = append(index.Docs, doc)
+
+		seen := map[string]bool{}
+		for _, term := range searchTerms(strings.Join(text, " ")) {
+			if !seen[term] {
+				seen[term] = true
+				index.Terms[term] = append(index.Terms[term], id)
+			}
+		}
+	}
+	bs, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	s.Emit(searchIndexPath, bs)
+
+	return s.renderPage("search/index.html", &component.EntryData{
+		Title: "Search",
+		Meta: component.Meta{NoIndex: true},
+		Content: []component.ContentElement{
+			component.SearchBox{},
+			component.SearchResults{Index: "/" + searchIndexPath},
+		},
+	})
+}
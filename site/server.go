@@ -0,0 +1,253 @@
+package site
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	liveReloadPath = "/_livereload"
+	// Injected into every html page served.
+	liveReloadSnippet = `<script>new EventSource("` + liveReloadPath + `").onmessage = () => location.reload();</script>`
+)
+
+// Server serves the site from memory, rebuilding it whenever a source
+// changes, and reloads the pages open in the browser.
+// Only meant for local development: the site is built as a dev build.
+type Server struct {
+	configPath, profile string
+	// if set, only those who know it are served, see NewPreviewServer
+	token string
+	mu sync.Mutex
+	site *Site
+	// after a failed incremental rebuild
+	stale bool
+	// closed and replaced by every successful build
+	rebuilt chan struct{}
+}
+
+// NewServer serves the site built from the config at configPath, with the
+// named profile applied (see LoadConfig).
+func NewServer(configPath, profile string) *Server {
+	return &Server{configPath: configPath, profile: profile, rebuilt: make(chan struct{})}
+}
+
+// NewPreviewServer is NewServer for sharing a preview before publishing:
+// drafts and entries published in the future are included, and only served
+// to those who know the token. It is passed as ?token= once (and then
+// remembered in a cookie) or as the password of basic auth.
+func NewPreviewServer(configPath, profile, token string) *Server {
+	srv := NewServer(configPath, profile)
+	srv.token = token
+	return srv
+}
+
+// Build (re)builds the site, the previous build keeps being served if it
+// fails.
+func (srv *Server) Build() error {
+	cfg, err := LoadConfig(srv.configPath, srv.profile)
+	if err != nil {
+		return err
+	}
+	cfg.Dev = true
+	if srv.token != "" {
+		cfg.Drafts, cfg.Future = true, true
+	}
+	s := New(cfg)
+	if err := s.Build(); err != nil {
+		return err
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.site, srv.stale = s, false
+	close(srv.rebuilt)
+	srv.rebuilt = make(chan struct{})
+	return nil
+}
+
+// Watch rebuilds the site whenever its sources change, incrementally unless
+// the config changed. It never returns.
+func (srv *Server) Watch() {
+	Watch(srv.watched, func(changed []string) {
+		start := time.Now()
+		if err := srv.Rebuild(changed); err != nil {
+			slog.Error("rebuild failed", "err", err)
+			return
+		}
+		slog.Info("rebuilt", "changed", strings.Join(changed, ", "), "duration", time.Since(start).Round(time.Millisecond).String())
+	})
+}
+
+func (srv *Server) watched() []string {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.site == nil {
+		return []string{srv.configPath}
+	}
+	return append(srv.site.Watched(), srv.configPath)
+}
+
+// Rebuild updates the site after the files changed.
+// The rebuild happens on a copy of the site, the previous build keeps being
+// served until it is done. After a failed incremental rebuild the next one
+// starts over with a full build.
+func (srv *Server) Rebuild(changed []string) error {
+	srv.mu.Lock()
+	s := srv.site
+	incremental := s != nil && !srv.stale && !slices.Contains(changed, filepath.Clean(srv.configPath))
+	srv.mu.Unlock()
+	if !incremental {
+		return srv.Build()
+	}
+	next := s.clone()
+	if err := next.Rebuild(changed); err != nil {
+		srv.mu.Lock()
+		srv.stale = true
+		srv.mu.Unlock()
+		return err
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.site = next
+	close(srv.rebuilt)
+	srv.rebuilt = make(chan struct{})
+	return nil
+}
+
+func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if srv.token != "" {
+		if !srv.authorized(w, r) {
+			return
+		}
+		w.Header().Set("X-Robots-Tag", "noindex")
+	}
+	if r.URL.Path == liveReloadPath {
+		srv.serveLiveReload(w, r)
+		return
+	}
+	srv.mu.Lock()
+	s := srv.site
+	srv.mu.Unlock()
+	if s == nil {
+		http.Error(w, "site not built yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	status := http.StatusOK
+	p, data, ok := s.lookup(r.URL.Path)
+	if !ok {
+		status = http.StatusNotFound
+		if p, data, ok = s.lookup("/404.html"); !ok {
+			http.NotFound(w, r)
+			return
+		}
+	}
+	if path.Ext(p) == ".html" {
+		data = bytes.Replace(data, []byte("</body>"), []byte(liveReloadSnippet+"</body>"), 1)
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	if status != http.StatusOK {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(status)
+		w.Write(data)
+		return
+	}
+	http.ServeContent(w, r, p, time.Time{}, bytes.NewReader(data))
+}
+
+const previewCookie = "blog-preview"
+
+// authorized checks the request carries the token of the preview, and asks
+// for it otherwise.
+// A token passed as ?token= is moved into a cookie, and the request
+// redirected to the url without it: urls with the token would end up in
+// the history, access logs and Referer headers.
+func (srv *Server) authorized(w http.ResponseWriter, r *http.Request) bool {
+	equal := func(a string) bool {
+		return subtle.ConstantTimeCompare([]byte(a), []byte(srv.token)) == 1
+	}
+	if query := r.URL.Query(); query.Has("token") && equal(query.Get("token")) {
+		// links on the pages do not carry the token
+		http.SetCookie(w, &http.Cookie{Name: previewCookie, Value: srv.token, Path: "/", HttpOnly: true, Secure: r.TLS != nil, SameSite: http.SameSiteLaxMode})
+		query.Del("token")
+		u := *r.URL
+		u.RawQuery = query.Encode()
+		http.Redirect(w, r, u.RequestURI(), http.StatusSeeOther)
+		return false
+	}
+	if c, err := r.Cookie(previewCookie); err == nil && equal(c.Value) {
+		return true
+	}
+	if _, password, ok := r.BasicAuth(); ok && equal(password) {
+		return true
+	}
+	w.Header().Set("WWW-Authenticate", `Basic realm="preview"`)
+	http.Error(w, "unauthorized", http.StatusUnauthorized)
+	return false
+}
+
+// lookup finds the output a url path refers to, the same way a static file
+// server would (directories serve their index.html).
+func (s *Site) lookup(urlPath string) (string, []byte, bool) {
+	p := strings.TrimPrefix(path.Clean("/"+urlPath), "/")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, candidate := range []string{p, path.Join(p, "index.html")} {
+		if data, ok := s.outputs[candidate]; ok && candidate != "" && candidate != "." {
+			return candidate, data, true
+		}
+	}
+	return "", nil, false
+}
+
+// serveLiveReload sends an event whenever the site was rebuilt.
+// @from: https://developer.mozilla.org/en-US/docs/Web/API/Server-sent_events
+func (srv *Server) serveLiveReload(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for {
+		srv.mu.Lock()
+		rebuilt := srv.rebuilt
+		srv.mu.Unlock()
+		select {
+		case <-r.Context().Done():
+			return
+		case <-rebuilt:
+			fmt.Fprintf(w, "data: reload\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// ListenAndServe builds the site, and serves it at addr while watching for
+// changes.
+func (srv *Server) ListenAndServe(addr string) error {
+	if err := srv.Build(); err != nil {
+		return err
+	}
+	go srv.Watch()
+	if srv.token != "" {
+		slog.Info("serving preview", "url", "http://"+addr, "auth", "?token= or basic auth password")
+	} else {
+		slog.Info("serving", "url", "http://"+addr)
+	}
+	return http.ListenAndServe(addr, srv)
+}
+
+var _ http.Handler = (*Server)(nil)
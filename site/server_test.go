@@ -0,0 +1,64 @@
+package site
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPreviewAuthorized(t *testing.T) {
+	srv := &Server{token: "secret"}
+	for _, tt := range []struct {
+		name string
+		req func() *http.Request
+		ok bool
+		status int
+		location string
+		secure bool
+	}{
+		{"no token", func() *http.Request {
+			return httptest.NewRequest(http.MethodGet, "/page.html", nil)
+		}, false, http.StatusUnauthorized, "", false},
+		{"wrong token", func() *http.Request {
+			return httptest.NewRequest(http.MethodGet, "/page.html?token=guess", nil)
+		}, false, http.StatusUnauthorized, "", false},
+		{"token in query", func() *http.Request {
+			return httptest.NewRequest(http.MethodGet, "/page.html?token=secret&a=b", nil)
+		}, false, http.StatusSeeOther, "/page.html?a=b", false},
+		{"token in query over tls", func() *http.Request {
+			r := httptest.NewRequest(http.MethodGet, "/?token=secret", nil)
+			r.TLS = &tls.ConnectionState{}
+			return r
+		}, false, http.StatusSeeOther, "/", true},
+		{"cookie", func() *http.Request {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.AddCookie(&http.Cookie{Name: previewCookie, Value: "secret"})
+			return r
+		}, true, http.StatusOK, "", false},
+		{"basic auth", func() *http.Request {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.SetBasicAuth("anyone", "secret")
+			return r
+		}, true, http.StatusOK, "", false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			if ok := srv.authorized(w, tt.req()); ok != tt.ok {
+				t.Errorf("authorized: %v, want %v", ok, tt.ok)
+			}
+			if w.Code != tt.status {
+				t.Errorf("status %d, want %d", w.Code, tt.status)
+			}
+			if got := w.Header().Get("Location"); got != tt.location {
+				t.Errorf("location %q, want %q", got, tt.location)
+			}
+			if tt.status == http.StatusSeeOther {
+				cookies := w.Result().Cookies()
+				if len(cookies) != 1 || cookies[0].Value != "secret" || cookies[0].Secure != tt.secure || !cookies[0].HttpOnly {
+					t.Errorf("cookies %v", cookies)
+				}
+			}
+		})
+	}
+}
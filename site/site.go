@@ -0,0 +1,695 @@
+// Package site builds the static blog from a directory of entry sources.
+package site
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"be/component"
+	"be/tok"
+)
+
+type (
+	Entry struct {
+		// Path to the *.be source file.
+		Source string
+		Slug string
+		Data *component.EntryData
+		LastMod time.Time
+		// of the page, see Path
+		path string
+		// offsets (in runes) of the start of each line of the source
+		lines []int
+	}
+
+	// Page is a publicly reachable html page of the generated site.
+	Page struct {
+		// Slash separated, relative to the site root.
+		Path string
+		LastMod time.Time
+		ChangeFreq string
+	}
+
+	Site struct {
+		Config Config
+		Entries []*Entry
+		Pages []Page
+		outputs map[string][]byte
+		// emitted since the last postprocess, and since the last Write
+		pending, unwritten map[string]bool
+		// of fingerprinted files, by their original url
+		renames map[string]string
+		// commit the git history was at when the build started
+		head string
+		// by source path
+		images map[string]*sourceImage
+		// guards outputs, pending, unwritten, images and warned, entries
+		// are built in parallel
+		mu sync.Mutex
+		// warnings already shown
+		warned map[string]bool
+		// received webmentions by canonical path of their target, see
+		// loadWebmentions
+		mentions map[string]component.Mentions
+		// registered from Go, by stage, see AddHook
+		hooks map[string][]HookFunc
+		// of _redirects or .htaccess, see buildRedirects
+		redirectRules []string
+		// posts announcing the entries, see loadSyndication
+		announced map[string]map[string]announced
+		// of the last build, see Report
+		stats *buildStats
+		// linked by every page
+		stylesheets, scripts []component.Asset
+		icons []component.Icon
+		manifest string
+	}
+)
+
+func New(cfg Config) *Site {
+	s := &Site{Config: cfg, warned: map[string]bool{}}
+	s.reset()
+	return s
+}
+
+// reset forgets everything built so far.
+func (s *Site) reset() {
+	s.Entries, s.Pages = nil, nil
+	s.stats = newBuildStats()
+	s.outputs = map[string][]byte{}
+	s.pending, s.unwritten = map[string]bool{}, map[string]bool{}
+	s.renames = map[string]string{}
+	s.images = map[string]*sourceImage{}
+}
+
+// clone copies the site, so that it can be rebuilt while the original
+// keeps being served. The entries and images are shared, the maps of what
+// was built are not.
+func (s *Site) clone() *Site {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &Site{
+		Config: s.Config,
+		Entries: slices.Clone(s.Entries),
+		Pages: slices.Clone(s.Pages),
+		outputs: maps.Clone(s.outputs),
+		pending: maps.Clone(s.pending),
+		unwritten: maps.Clone(s.unwritten),
+		renames: maps.Clone(s.renames),
+		head: s.head,
+		images: maps.Clone(s.images),
+		warned: maps.Clone(s.warned),
+		mentions: s.mentions,
+		hooks: s.hooks,
+		redirectRules: s.redirectRules,
+		announced: s.announced,
+		stats: s.stats,
+		stylesheets: s.stylesheets,
+		scripts: s.scripts,
+		icons: s.icons,
+		manifest: s.manifest,
+	}
+}
+
+// Path is where the page of the entry is emitted, relative to the output
+// directory. It follows Config.Permalink for entries loaded by the site.
+func (e *Entry) Path() string {
+	if e.path == "" {
+		return e.Slug + ".html"
+	}
+	return e.path
+}
+
+// URL returns the absolute, canonical url of the site relative path p.
+func (s *Site) URL(p string) string {
+	return strings.TrimSuffix(s.Config.BaseURL, "/") + Canonical(p)
+}
+
+// Canonical maps all variants of a page path onto a single form:
+// index.html is dropped in favour of its directory, and directories always
+// end in a trailing slash.
+//   - "", "/", "index.html" => "/"
+//   - "tags", "tags/", "tags/index.html" => "/tags/"
+//   - "entry.html" => "/entry.html"
+func Canonical(p string) string {
+	p = path.Clean("/" + p)
+	if path.Base(p) == "index.html" {
+		p = path.Dir(p)
+	}
+	if p != "/" && path.Ext(p) == "" {
+		p += "/"
+	}
+	return p
+}
+
+// Emit registers data to be written to the output directory at path p
+// (relative to it, a leading slash is ignored).
+func (s *Site) Emit(p string, data []byte) {
+	p = strings.TrimPrefix(path.Clean("/"+p), "/")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.outputs[p] = data
+	s.pending[p], s.unwritten[p] = true, true
+}
+
+// remove takes back an emitted file, along with its precompressed copies.
+func (s *Site) remove(p string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range []string{p, p + ".gz", p + ".br"} {
+		delete(s.outputs, p)
+		delete(s.pending, p)
+		delete(s.unwritten, p)
+	}
+}
+
+// Outputs lists the paths of all emitted files, sorted.
+func (s *Site) Outputs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ps := make([]string, 0, len(s.outputs))
+	for p := range s.outputs {
+		ps = append(ps, p)
+	}
+	sort.Strings(ps)
+	return ps
+}
+
+// pendingOutputs lists the paths of the files emitted since the last
+// postprocess, sorted.
+func (s *Site) pendingOutputs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ps := make([]string, 0, len(s.pending))
+	for p := range s.pending {
+		ps = append(ps, p)
+	}
+	sort.Strings(ps)
+	return ps
+}
+
+func LoadEntry(source string) (*Entry, error) {
+	return loadSource(source, nil)
+}
+
+// loadSource reads the entry, with the parse cache and stats of the site if
+// it is not nil.
+func loadSource(source string, s *Site) (*Entry, error) {
+	bs, err := os.ReadFile(source)
+	if err != nil {
+		return nil, err
+	}
+	src := []rune(string(bs))
+	var data *component.EntryData
+	if s != nil {
+		data, err = s.parseCached(src, bs)
+	} else {
+		data, err = parse(src, nil)
+	}
+	if err != nil {
+		var perr *tok.Error
+		if errors.As(err, &perr) {
+			return nil, tok.Locate(err, source, src) // file:line:column: ...
+		}
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+	lines := []int{0}
+	for i, r := range src {
+		if r == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+	return &Entry{
+		Source: source,
+		Slug: strings.TrimSuffix(filepath.Base(source), filepath.Ext(source)),
+		Data: data,
+		lines: lines,
+	}, nil
+}
+
+// Position formats the offset (in runes) into the source of the entry as
+// file:line:column.
+func (e *Entry) Position(pos int) string {
+	line, _ := slices.BinarySearch(e.lines, pos+1) // first line starting after pos
+	if line == 0 {
+		return e.Source
+	}
+	return fmt.Sprintf("%s:%d:%d", e.Source, line, pos-e.lines[line-1]+1)
+}
+
+// Load reads all entries from the sources directory, most recently
+// published first.
+// The sources are parsed in parallel, the errors of all of them reported.
+func (s *Site) Load() error {
+	var sources []string
+	err := filepath.WalkDir(s.Config.Sources, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(p) != ".be" {
+			return err
+		}
+		sources = append(sources, p)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	entries := make([]*Entry, len(sources))
+	err = parallel(len(sources), func(i int) (err error) {
+		entries[i], err = s.loadEntry(sources[i])
+		return err
+	})
+	for _, e := range entries {
+		if e != nil && s.included(e) {
+			s.Entries = append(s.Entries, e)
+		}
+	}
+	s.sortEntries()
+	if err == nil {
+		err = s.checkPermalinks()
+	}
+	slog.Debug("loaded entries", "sources", s.Config.Sources, "entries", len(s.Entries))
+	return err
+}
+
+func (s *Site) loadEntry(source string) (*Entry, error) {
+	e, err := loadSource(source, s)
+	if err != nil {
+		return nil, err
+	}
+	s.applyDefaults(e)
+	if e.path, err = s.permalink(e); err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+	meta := &e.Data.Meta
+	meta.Outdated = meta.Archived || (!meta.Expires.IsZero() && time.Now().After(meta.Expires))
+	if meta.Outdated && s.Config.NoIndexOutdated {
+		meta.NoIndex = true
+	}
+	if meta.Draft {
+		meta.NoIndex = true
+	}
+	return e, nil
+}
+
+// checkPermalinks makes sure no two entries are emitted to the same page.
+func (s *Site) checkPermalinks() error {
+	paths := map[string]*Entry{}
+	for _, e := range s.Entries {
+		if other, ok := paths[e.Path()]; ok {
+			return fmt.Errorf("%s: same permalink as %s: %s", e.Source, other.Source, Canonical(e.Path()))
+		}
+		paths[e.Path()] = e
+	}
+	return nil
+}
+
+// included reports whether the entry is built, drafts and entries
+// published in the future only are if the config asks for them.
+func (s *Site) included(e *Entry) bool {
+	meta := e.Data.Meta
+	return (!meta.Draft || s.Config.Drafts) && (!meta.Published.After(time.Now()) || s.Config.Future)
+}
+
+func (s *Site) sortEntries() {
+	sort.SliceStable(s.Entries, func(i, j int) bool {
+		return s.Entries[i].Data.Meta.Published.After(s.Entries[j].Data.Meta.Published)
+	})
+}
+
+func (s *Site) applyDefaults(e *Entry) {
+	if s.Config.BlogName != "" {
+		e.Data.BlogName = s.Config.BlogName
+	}
+	if e.Data.Author.Name == "" {
+		e.Data.Author = s.Config.Author
+	}
+	if e.Data.Meta.Language == "" {
+		e.Data.Meta.Language = s.Config.Language
+	}
+	e.Data.Meta.Stylesheets = s.stylesheets
+	e.Data.Meta.Scripts = s.scripts
+	e.Data.Meta.Icons = s.icons
+	e.Data.Meta.Manifest = s.manifest
+	e.Data.Meta.ThemeColor = s.Config.ThemeColor
+	e.Data.Meta.ThemeToggle = s.Config.Themes.Toggle
+	e.Data.Meta.Analytics = template.HTML(s.Config.Analytics)
+	e.Data.Meta.Webmention = s.Config.Webmentions.Endpoint
+	s.applyIdentity(&e.Data.Meta)
+}
+
+// applyIdentity links the profiles of the author, and the IndieAuth server.
+func (s *Site) applyIdentity(meta *component.Meta) {
+	meta.Identities = s.Config.Identities
+	meta.AuthorizationEndpoint = s.Config.IndieAuth.AuthorizationEndpoint
+	meta.TokenEndpoint = s.Config.IndieAuth.TokenEndpoint
+	meta.IndieAuthMetadata = s.Config.IndieAuth.Metadata
+}
+
+// Recent returns up to n of the most recently published entries of the
+// language that are not outdated.
+func (s *Site) Recent(lang string, n int) []component.PostItem {
+	items := []component.PostItem{}
+	for _, e := range s.EntriesIn(lang) {
+		if len(items) >= n {
+			break
+		}
+		if !e.Data.Meta.Outdated {
+			items = append(items, s.postItem(e))
+		}
+	}
+	return items
+}
+
+// Outdated returns the archived and expired entries of the language.
+func (s *Site) Outdated(lang string) []component.PostItem {
+	items := []component.PostItem{}
+	for _, e := range s.EntriesIn(lang) {
+		if e.Data.Meta.Outdated {
+			items = append(items, s.postItem(e))
+		}
+	}
+	return items
+}
+
+func (s *Site) postItem(e *Entry) component.PostItem {
+	return component.PostItem{
+		Title: e.Data.Title,
+		Link: Canonical(e.Path()),
+		URL: s.URL(e.Path()),
+		Published: e.Data.Meta.Published,
+		Excerpt: e.Data.Excerpt(),
+		Tags: e.Data.Tags,
+	}
+}
+
+// setSiteData tells the templates about the (loaded) entries.
+func (s *Site) setSiteData() {
+	data := &component.SiteData{
+		Name: s.Config.BlogName,
+		Description: s.Config.Description,
+		Language: s.Config.Language,
+		BaseURL: s.Config.BaseURL,
+		Author: s.Config.Author,
+		Tags: map[string][]component.PostItem{},
+		Params: s.Config.Params,
+	}
+	for _, e := range s.Entries {
+		data.Posts = append(data.Posts, s.postItem(e))
+	}
+	for _, t := range s.Taxonomy() {
+		for _, e := range t.Entries {
+			data.Tags[t.Tag.Name()] = append(data.Tags[t.Tag.Name()], s.postItem(e))
+		}
+	}
+	component.SetSite(data)
+}
+
+func (s *Site) Build() error {
+	s.reset()
+	defer s.stats.done()
+	s.head = gitHead()
+	if err := component.LoadTheme(s.Config.Theme); err != nil {
+		return err
+	}
+	component.SetCache(s.cached)
+	done := s.stats.timed("assets")
+	if err := s.buildAssets(); err != nil {
+		return err
+	}
+	if err := s.buildIcons(); err != nil {
+		return err
+	}
+	done()
+	if err := s.Load(); err != nil {
+		return err
+	}
+	if err := s.loadWebmentions(); err != nil {
+		return err
+	}
+	s.loadSyndication()
+	s.linkTranslations()
+	if err := s.resolveLinks(); err != nil {
+		return err
+	}
+	s.setSiteData()
+	err := parallel(len(s.Entries), func(i int) error {
+		return s.buildEntry(s.Entries[i])
+	})
+	if err != nil {
+		return err
+	}
+	done = s.stats.timed("assets")
+	if err := s.copyPublic(); err != nil {
+		return err
+	}
+	done()
+	return s.buildAggregates()
+}
+
+// buildEntry renders the page of a freshly loaded entry, whose links are
+// already resolved.
+// Safe to call for several entries at the same time.
+func (s *Site) buildEntry(e *Entry) error {
+	e.Data.Meta.CanonicalURL = s.URL(e.Path())
+	e.Data.Meta.FeedURL = s.URL(s.LangPath(e.Data.Meta.Language, "rss.xml"))
+	done := s.stats.timed("images")
+	if err := s.processImages(e); err != nil {
+		done()
+		return err
+	}
+	img, err := s.previewImage(e)
+	done()
+	if err != nil {
+		return fmt.Errorf("%s: %w", e.Source, err)
+	}
+	e.Data.Meta.Image = img
+	e.Data.Copies = s.syndicationOf(e)
+	e.Data.Mentions = s.mentionsOf(e)
+	if e.Data.Comments, err = s.commentsOf(e); err != nil {
+		return fmt.Errorf("%s: %w", e.Source, err)
+	}
+	e.Data.CommentForm = s.commentForm(e)
+	done = s.stats.timed("render")
+	buf := &bytes.Buffer{}
+	err = component.RenderEntry(buf, e.Data)
+	done()
+	if err != nil {
+		return fmt.Errorf("%s: %w", e.Source, err)
+	}
+	s.Emit(e.Path(), buf.Bytes())
+	e.LastMod = s.lastMod(e)
+	slog.Debug("built entry", "source", e.Source, "page", e.Path())
+	return nil
+}
+
+// buildAggregates renders everything made up of all entries (indexes,
+// feeds, tags, ...), and postprocesses what was emitted since the last time.
+func (s *Site) buildAggregates() error {
+	done := s.stats.timed("aggregates")
+	err := s.renderAggregates()
+	done()
+	if err != nil {
+		return err
+	}
+	return s.postprocess()
+}
+
+func (s *Site) renderAggregates() error {
+	s.Pages = nil
+	for _, e := range s.Entries {
+		if e.Data.Meta.NoIndex {
+			continue
+		}
+		s.Pages = append(s.Pages, Page{
+			Path: e.Path(),
+			LastMod: e.LastMod,
+			ChangeFreq: changeFreq(e.LastMod),
+		})
+	}
+	if err := s.buildIndex(); err != nil {
+		return err
+	}
+	if err := s.buildFeeds(); err != nil {
+		return err
+	}
+	if err := s.buildTags(); err != nil {
+		return err
+	}
+	if err := s.buildSearch(); err != nil {
+		return err
+	}
+	if err := s.buildNotFound(); err != nil {
+		return err
+	}
+	if err := s.buildRedirects(); err != nil {
+		return err
+	}
+	if err := s.buildActivityPub(); err != nil {
+		return err
+	}
+	if err := s.buildWellKnown(); err != nil {
+		return err
+	}
+	if err := s.buildSitemap(); err != nil {
+		return err
+	}
+	s.buildRobots()
+	return nil
+}
+
+// postprocess runs the production passes over the files emitted since the
+// last time.
+func (s *Site) postprocess() error {
+	defer s.stats.timed("postprocess")()
+	if !s.Config.Dev {
+		if s.Config.Fingerprint {
+			s.fingerprint()
+		}
+		if s.Config.Minify {
+			if err := s.minifyPages(); err != nil {
+				return err
+			}
+		}
+	}
+	// the policy hashes the inline scripts of the pages as they are served
+	if err := s.buildServerConfig(); err != nil {
+		return err
+	}
+	if !s.Config.Dev && s.Config.Precompress {
+		if err := s.precompress(); err != nil {
+			return err
+		}
+	}
+	s.pending = map[string]bool{}
+	return nil
+}
+
+// warnOnce logs the warning (with slog key value pairs as args), unless one
+// with the same key was already logged.
+func (s *Site) warnOnce(key, msg string, args ...any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.warned[key] {
+		s.warned[key] = true
+		slog.Warn(msg, args...)
+	}
+}
+
+// renderPage renders a page that is not backed by an entry source, but
+// otherwise looks like any other entry.
+func (s *Site) renderPage(p string, data *component.EntryData) error {
+	page := &Entry{Source: p, Data: data}
+	s.applyDefaults(page)
+	if data.Meta.CanonicalURL == "" {
+		data.Meta.CanonicalURL = s.URL(p)
+	}
+	buf := &bytes.Buffer{}
+	if err := component.RenderEntry(buf, data); err != nil {
+		return fmt.Errorf("%s: %w", p, err)
+	}
+	s.Emit(p, buf.Bytes())
+	return nil
+}
+
+// previewImage is the explicitly set preview image, otherwise the first
+// image in the content of the entry, or else a generated one.
+func (s *Site) previewImage(e *Entry) (string, error) {
+	img := e.Data.Meta.Image
+	if img == "" {
+		if first := e.Data.FirstImage(); first != nil {
+			img = first.Path
+		}
+	}
+	if img == "" && s.Config.PreviewImages {
+		if err := s.buildPreviewImage(e); err != nil {
+			return "", err
+		}
+		img = previewPath(e)
+	}
+	if img == "" || strings.Contains(img, "://") {
+		return img, nil
+	}
+	return s.URL(img), nil
+}
+
+func (s *Site) copyPublic() error {
+	return filepath.WalkDir(s.Config.Public, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		return s.copyPublicFile(p)
+	})
+}
+
+// copyPublicFile copies a single file of the public directory, or takes it
+// back if it no longer exists.
+func (s *Site) copyPublicFile(p string) error {
+	rel, err := filepath.Rel(s.Config.Public, p)
+	if err != nil || s.bundled(filepath.ToSlash(rel)) {
+		return err
+	}
+	out := path.Join("public", filepath.ToSlash(rel))
+	bs, err := os.ReadFile(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		s.remove(out)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	s.Emit(out, stripMetadata(bs))
+	return nil
+}
+
+// Write writes the files emitted since the last Write (all of them, the
+// first time) into the output directory.
+// Files that are still exactly as an earlier Write left them are not touched
+// again, they keep their modification time.
+func (s *Site) Write() error {
+	defer s.stats.timed("write")()
+	written := s.loadWritten()
+	for _, p := range s.Outputs() {
+		if !s.unwritten[p] {
+			continue
+		}
+		dst := filepath.Join(s.Config.Output, filepath.FromSlash(p))
+		sum := sha256.Sum256(s.outputs[p])
+		hash := hex.EncodeToString(sum[:])
+		if fi, err := os.Stat(dst); err == nil && written[dst] == stamp(hash, fi) {
+			slog.Debug("unchanged, not writing", "path", dst)
+			delete(s.unwritten, p)
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(dst, s.outputs[p], 0644); err != nil {
+			return err
+		}
+		slog.Debug("wrote", "path", dst)
+		if fi, err := os.Stat(dst); err == nil {
+			written[dst] = stamp(hash, fi)
+		}
+		delete(s.unwritten, p)
+	}
+	return s.saveWritten(written)
+}
+
+// stamp changes if the file is modified after it was written.
+func stamp(hash string, fi fs.FileInfo) string {
+	return fmt.Sprintf("%s %d %d", hash, fi.Size(), fi.ModTime().UnixNano())
+}
@@ -0,0 +1,133 @@
+package site
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// @from: https://www.sitemaps.org/protocol.html
+const (
+	sitemapNS = "http://www.sitemaps.org/schemas/sitemap/0.9"
+	maxSitemapURLs = 50000
+)
+
+type (
+	sitemapURLSet struct {
+		XMLName xml.Name `xml:"urlset"`
+		NS string `xml:"xmlns,attr"`
+		URLs []sitemapURL `xml:"url"`
+	}
+	sitemapURL struct {
+		Loc string `xml:"loc"`
+		LastMod string `xml:"lastmod,omitempty"`
+		ChangeFreq string `xml:"changefreq,omitempty"`
+	}
+	sitemapIndex struct {
+		XMLName xml.Name `xml:"sitemapindex"`
+		NS string `xml:"xmlns,attr"`
+		Sitemaps []sitemapRef `xml:"sitemap"`
+	}
+	sitemapRef struct {
+		Loc string `xml:"loc"`
+		LastMod string `xml:"lastmod,omitempty"`
+	}
+)
+
+func (s *Site) buildSitemap() error {
+	urls := make([]sitemapURL, len(s.Pages))
+	for i, p := range s.Pages {
+		urls[i] = sitemapURL{
+			Loc: s.URL(p.Path),
+			ChangeFreq: p.ChangeFreq,
+		}
+		if !p.LastMod.IsZero() {
+			urls[i].LastMod = p.LastMod.Format(time.DateOnly)
+		}
+	}
+	if len(urls) <= maxSitemapURLs {
+		return s.emitXML("sitemap.xml", sitemapURLSet{NS: sitemapNS, URLs: urls})
+	}
+
+	// too many urls for a single file, split them up and reference the
+	// parts from a sitemap index instead
+	index := sitemapIndex{NS: sitemapNS}
+	for i := 0; i*maxSitemapURLs < len(urls); i++ {
+		part := urls[i*maxSitemapURLs:min((i+1)*maxSitemapURLs, len(urls))]
+		name := fmt.Sprintf("sitemap-%d.xml", i+1)
+		if err := s.emitXML(name, sitemapURLSet{NS: sitemapNS, URLs: part}); err != nil {
+			return err
+		}
+		ref := sitemapRef{Loc: s.URL(name)}
+		var last string
+		for _, u := range part {
+			last = max(last, u.LastMod)
+		}
+		ref.LastMod = last
+		index.Sitemaps = append(index.Sitemaps, ref)
+	}
+	return s.emitXML("sitemap.xml", index)
+}
+
+func (s *Site) emitXML(p string, v any) error {
+	bs, err := xml.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return err
+	}
+	s.Emit(p, append([]byte(xml.Header), bs...))
+	return nil
+}
+
+// lastMod prefers the revision date given in the entry, then the date of the
+// last commit touching the source, then the publishing date, and as a last
+// resort the modification time of the source file.
+func (s *Site) lastMod(e *Entry) time.Time {
+	if e.Data.Meta.IsRevised() {
+		return e.Data.Meta.LastRevised()
+	}
+	if t, err := s.gitLastMod(e.Source); err == nil {
+		return t
+	}
+	if !e.Data.Meta.Published.IsZero() {
+		return e.Data.Meta.Published
+	}
+	if fi, err := os.Stat(e.Source); err == nil {
+		return fi.ModTime()
+	}
+	return time.Time{}
+}
+
+// gitLastMod asks git only once per commit and version of the file.
+func (s *Site) gitLastMod(file string) (time.Time, error) {
+	bs, err := os.ReadFile(file)
+	if err != nil {
+		return time.Time{}, err
+	}
+	out, err := s.cached("lastmod", func() ([]byte, error) {
+		return exec.Command("git", "log", "-1", "--format=%cI", "--", file).Output()
+	}, []byte(s.head), []byte(file), bs)
+	if err != nil {
+		return time.Time{}, err
+	}
+	date := strings.TrimSpace(string(out))
+	if date == "" {
+		return time.Time{}, fmt.Errorf("%s: not tracked by git", file)
+	}
+	return time.Parse(time.RFC3339, date)
+}
+
+// changeFreq is only a hint, crawlers are free to ignore it.
+func changeFreq(lastMod time.Time) string {
+	age := time.Since(lastMod)
+	switch {
+	case age < 30*24*time.Hour:
+		return "weekly"
+	case age < 365*24*time.Hour:
+		return "monthly"
+	default:
+		return "yearly"
+	}
+}
@@ -0,0 +1,227 @@
+package site
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"be/component"
+)
+
+type Spell struct {
+	// Dictionary by language of the entries, e.g. {"en": "en_US"}: the name
+	// of a hunspell dictionary, or the path to a .dic file or a list of
+	// words (one per line).
+	// Hunspell is used if it is installed (and the dictionary is not a list
+	// of words). Otherwise the words of the .dic
+	// file are taken as they are, without their affixes (e.g. plurals), so
+	// a complete list of words works better.
+	Dictionaries map[string]string `json:"dictionaries"`
+	// File with the words of the site (names, jargon, ...) that are spelled
+	// correctly, one per line.
+	Words string `json:"words"`
+}
+
+// Where hunspell (and thus the built-in checker) looks for dictionaries.
+var dictionaryDirs = []string{"/usr/share/hunspell", "/usr/share/myspell", "/usr/share/myspell/dicts", "/Library/Spelling"}
+
+// Misspelling is a word of an entry not found in the dictionary of its
+// language.
+type Misspelling struct {
+	Entry *Entry
+	Word string
+	// Offset (in runes) of the word in the source.
+	Pos int
+}
+
+func (m Misspelling) Error() string {
+	return fmt.Sprintf("%s: misspelled: %s", m.Entry.Position(m.Pos), m.Word)
+}
+
+// Spellcheck checks the text (but not the code) of the entries, in the order
+// the words appear in them.
+// Call it after Load.
+func (s *Site) Spellcheck() ([]Misspelling, error) {
+	known, err := readWordList(s.Config.Spell.Words)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	checkers := map[string]func(words []string) (map[string]bool, error){}
+	var found []Misspelling
+	for _, e := range s.Entries {
+		lang := e.Data.Meta.Language
+		check, ok := checkers[lang]
+		if !ok {
+			check, err = s.spellchecker(lang)
+			if err != nil {
+				return found, err
+			}
+			checkers[lang] = check
+		}
+		// the source is searched for the words, to report where they are
+		src, err := os.ReadFile(e.Source)
+		if err != nil {
+			return found, err
+		}
+		words := spellWords(e.Data.Title + "\n\n" + e.Data.Meta.Description + "\n\n" + proseText(e.Data.Content))
+		var unknown []string
+		for _, w := range words {
+			if !known[w] && !known[strings.ToLower(w)] {
+				unknown = append(unknown, w)
+			}
+		}
+		if len(unknown) == 0 {
+			continue
+		}
+		wrong, err := check(unknown)
+		if err != nil {
+			return found, fmt.Errorf("%s: %w", e.Source, err)
+		}
+		source := []rune(string(src))
+		from := 0
+		for _, w := range unknown {
+			if !wrong[w] {
+				continue
+			}
+			pos := findWord(source, w, from)
+			if pos < 0 {
+				pos = findWord(source, w, 0)
+			} else {
+				from = pos + utf8.RuneCountInString(w)
+			}
+			found = append(found, Misspelling{Entry: e, Word: w, Pos: max(pos, 0)})
+		}
+	}
+	return found, nil
+}
+
+// proseText is the plain text of the content, without code blocks.
+func proseText(content []component.ContentElement) string {
+	var prose []component.ContentElement
+	for _, c := range content {
+		if _, ok := c.(*component.CodeBlock); !ok {
+			prose = append(prose, c)
+		}
+	}
+	return component.PlainText(prose)
+}
+
+// spellWords splits text into its words, in order. Words with digits in
+// them (versions, units, ...) and urls are skipped.
+func spellWords(text string) []string {
+	var words []string
+	for _, field := range strings.Fields(text) {
+		if strings.Contains(field, "://") || strings.Contains(field, "@") {
+			continue
+		}
+		for _, w := range strings.FieldsFunc(field, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\'' && r != '’'
+		}) {
+			w = strings.Trim(w, "'’")
+			if w != "" && !strings.ContainsFunc(w, unicode.IsDigit) {
+				words = append(words, w)
+			}
+		}
+	}
+	return words
+}
+
+// findWord returns the offset of the first occurrence of the whole word w in
+// src at or after from, or -1.
+func findWord(src []rune, w string, from int) int {
+	word := []rune(w)
+	isLetter := func(i int) bool {
+		return i >= 0 && i < len(src) && unicode.IsLetter(src[i])
+	}
+	for i := from; i+len(word) <= len(src); i++ {
+		if slices.Equal(src[i:i+len(word)], word) && !isLetter(i-1) && !isLetter(i+len(word)) {
+			return i
+		}
+	}
+	return -1
+}
+
+// spellchecker returns a function that picks the misspelled ones from a list
+// of words in the language.
+func (s *Site) spellchecker(lang string) (func(words []string) (map[string]bool, error), error) {
+	dict, ok := s.Config.Spell.Dictionaries[lang]
+	if !ok {
+		return nil, fmt.Errorf("no dictionary configured for language %s", lang)
+	}
+	_, err := os.Stat(dict)
+	wordList := err == nil && filepath.Ext(dict) != ".dic"
+	if _, err := exec.LookPath("hunspell"); err == nil && !wordList {
+		return func(words []string) (map[string]bool, error) {
+			// -l lists the misspelled words of the input
+			cmd := exec.Command("hunspell", "-d", strings.TrimSuffix(dict, ".dic"), "-l")
+			cmd.Stdin = strings.NewReader(strings.Join(words, "\n"))
+			out, err := cmd.Output()
+			if err != nil {
+				return nil, fmt.Errorf("hunspell: %w", err)
+			}
+			wrong := map[string]bool{}
+			for _, w := range strings.Fields(string(out)) {
+				wrong[w] = true
+			}
+			return wrong, nil
+		}, nil
+	}
+	p := dict
+	if _, err := os.Stat(p); err != nil {
+		for _, dir := range dictionaryDirs {
+			if _, err := os.Stat(filepath.Join(dir, dict+".dic")); err == nil {
+				p = filepath.Join(dir, dict+".dic")
+				break
+			}
+		}
+	}
+	known, err := readWordList(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("dictionary %s of language %s not found, and hunspell is not installed", dict, lang)
+	} else if err != nil {
+		return nil, err
+	}
+	return func(words []string) (map[string]bool, error) {
+		wrong := map[string]bool{}
+		for _, w := range words {
+			lower := strings.ToLower(w)
+			// capitalized at the start of a sentence
+			capitalized := len(w) > 0 && unicode.IsUpper([]rune(w)[0]) && known[lower]
+			if !known[w] && !capitalized {
+				wrong[w] = true
+			}
+		}
+		return wrong, nil
+	}, nil
+}
+
+// readWordList reads a list of words, one per line. Hunspell .dic files work
+// as well: the count on their first line and the affix flags (word/FLAGS)
+// are ignored.
+func readWordList(p string) (map[string]bool, error) {
+	words := map[string]bool{}
+	if p == "" {
+		return words, nil
+	}
+	bs, err := os.ReadFile(p)
+	if err != nil {
+		return words, err
+	}
+	sc := bufio.NewScanner(bytes.NewReader(bs))
+	for sc.Scan() {
+		w, _, _ := strings.Cut(strings.TrimSpace(sc.Text()), "/")
+		if w != "" && !strings.HasPrefix(w, "#") && strings.Trim(w, "0123456789") != "" {
+			words[w] = true
+		}
+	}
+	return words, sc.Err()
+}
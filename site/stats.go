@@ -0,0 +1,223 @@
+package site
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"be/component"
+)
+
+var (
+	// combined log format, the default of nginx (and apache)
+	combinedLogPattern = regexp.MustCompile(`^(\S+) \S+ \S+ \[([^\]]+)\] "(\S+) (\S+)[^"]*" (\d{3}) \S+ "([^"]*)" "([^"]*)"`)
+	// user agents of crawlers, feed readers, link previews, monitoring and
+	// scripts
+	botPattern = regexp.MustCompile(`(?i)bot|crawl|spider|slurp|scan|fetch|curl|wget|python|go-http|java/|libwww|httpclient|okhttp|headless|lighthouse|pingdom|uptime|monitor|preview|facebookexternalhit|feed|rss|mastodon|pleroma|akkoma|misskey`)
+)
+
+// AccessStats counts the page views in access logs of the web server.
+// Only successful GET requests of pages count, no assets, no bots. Visitors
+// are told apart by address and user agent per day, neither is kept.
+type AccessStats struct {
+	// of the site, referrals from it are not counted
+	host string
+	views, referrers, days map[string]int
+	visitors map[[32]byte]bool
+	bots int
+	from, to time.Time
+}
+
+// NewAccessStats counts the visits of the site.
+func (s *Site) NewAccessStats() *AccessStats {
+	st := &AccessStats{
+		views: map[string]int{},
+		referrers: map[string]int{},
+		days: map[string]int{},
+		visitors: map[[32]byte]bool{},
+	}
+	if u, err := url.Parse(s.Config.BaseURL); err == nil {
+		st.host = u.Hostname()
+	}
+	return st
+}
+
+// ReadFile reads an access log, gzip compressed if its name ends in .gz
+// (rotated logs).
+func (st *AccessStats) ReadFile(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(name, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	return st.Read(r)
+}
+
+// Read reads an access log, in the combined log format (nginx) or as json
+// (caddy). Lines in neither format are skipped.
+func (st *AccessStats) Read(r io.Reader) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		if req, ok := parseAccessLine(sc.Text()); ok {
+			st.count(req)
+		}
+	}
+	return sc.Err()
+}
+
+type accessRequest struct {
+	addr, method, uri, referrer, agent string
+	status int
+	time time.Time
+}
+
+func parseAccessLine(line string) (accessRequest, bool) {
+	var req accessRequest
+	if strings.HasPrefix(line, "{") {
+		// @from: https://caddyserver.com/docs/caddyfile/directives/log
+		var entry struct {
+			TS json.RawMessage `json:"ts"`
+			Status int `json:"status"`
+			Request struct {
+				RemoteIP string `json:"remote_ip"`
+				Method string `json:"method"`
+				URI string `json:"uri"`
+				Headers map[string][]string `json:"headers"`
+			} `json:"request"`
+		}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil || entry.Request.Method == "" {
+			return req, false
+		}
+		req = accessRequest{
+			addr: entry.Request.RemoteIP,
+			method: entry.Request.Method,
+			uri: entry.Request.URI,
+			status: entry.Status,
+		}
+		if ref := entry.Request.Headers["Referer"]; len(ref) > 0 {
+			req.referrer = ref[0]
+		}
+		if ua := entry.Request.Headers["User-Agent"]; len(ua) > 0 {
+			req.agent = ua[0]
+		}
+		var ts any
+		json.Unmarshal(entry.TS, &ts)
+		switch ts := ts.(type) {
+		case float64:
+			req.time = time.Unix(int64(ts), int64((ts-float64(int64(ts)))*1e9))
+		case string:
+			req.time, _ = time.Parse(time.RFC3339Nano, ts)
+		}
+		return req, !req.time.IsZero()
+	}
+	m := combinedLogPattern.FindStringSubmatch(line)
+	if m == nil {
+		return req, false
+	}
+	t, err := time.Parse("02/Jan/2006:15:04:05 -0700", m[2])
+	if err != nil {
+		return req, false
+	}
+	status, _ := strconv.Atoi(m[5])
+	return accessRequest{
+		addr: m[1],
+		time: t,
+		method: m[3],
+		uri: m[4],
+		status: status,
+		referrer: m[6],
+		agent: m[7],
+	}, true
+}
+
+func (st *AccessStats) count(req accessRequest) {
+	if req.method != "GET" || (req.status != 200 && req.status != 304) {
+		return
+	}
+	u, err := url.Parse(req.uri)
+	if err != nil {
+		return
+	}
+	if ext := path.Ext(u.Path); ext != "" && ext != ".html" {
+		return // assets, feeds, ...
+	}
+	if req.agent == "" || req.agent == "-" || botPattern.MatchString(req.agent) {
+		st.bots++
+		return
+	}
+	page := Canonical(u.Path)
+	day := req.time.Format(time.DateOnly)
+	st.views[page]++
+	st.days[day]++
+	st.visitors[sha256.Sum256([]byte(req.addr+"\n"+req.agent+"\n"+day))] = true
+	if ref, err := url.Parse(req.referrer); err == nil && ref.Host != "" && ref.Hostname() != st.host {
+		st.referrers[strings.TrimPrefix(ref.Hostname(), "www.")]++
+	}
+	if st.from.IsZero() || req.time.Before(st.from) {
+		st.from = req.time
+	}
+	if req.time.After(st.to) {
+		st.to = req.time
+	}
+}
+
+// Stats summarizes what was read, with the top pages and referrers.
+func (st *AccessStats) Stats(top int) *component.Stats {
+	stats := &component.Stats{
+		From: st.from,
+		To: st.to,
+		Visitors: len(st.visitors),
+		Bots: st.bots,
+		Pages: statsRows(st.views, top, false),
+		Referrers: statsRows(st.referrers, top, false),
+		Days: statsRows(st.days, 0, true),
+	}
+	for _, n := range st.views {
+		stats.Views += n
+	}
+	return stats
+}
+
+// statsRows lists the counts, the largest first (or by label), at most top
+// of them if top > 0.
+func statsRows(counts map[string]int, top int, byLabel bool) []component.StatsRow {
+	rows := make([]component.StatsRow, 0, len(counts))
+	largest := 0
+	for label, n := range counts {
+		rows = append(rows, component.StatsRow{Label: label, Count: n})
+		largest = max(largest, n)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if byLabel || rows[i].Count == rows[j].Count {
+			return rows[i].Label < rows[j].Label
+		}
+		return rows[i].Count > rows[j].Count
+	})
+	if top > 0 && len(rows) > top {
+		rows = rows[:top]
+	}
+	for i := range rows {
+		rows[i].Percent = rows[i].Count * 100 / largest
+	}
+	return rows
+}
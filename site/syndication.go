@@ -0,0 +1,53 @@
+package site
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"sort"
+	"strings"
+
+	"be/component"
+)
+
+// loadSyndication reads the posts made by Announce, for the entries to link
+// the copies on the services.
+func (s *Site) loadSyndication() {
+	state, err := s.loadAnnounced()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		slog.Warn("cannot link the announcements of the entries", "err", err)
+	}
+	s.announced = state
+}
+
+// syndicationOf the entry: the copies named in its source, then the posts
+// announcing it.
+func (s *Site) syndicationOf(e *Entry) []component.Syndication {
+	urls := append([]string(nil), e.Data.Syndication...)
+	var names []string
+	for name := range s.announced {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		// empty for the entries published before the first announcement
+		if status := s.announced[name][e.Slug].Status; status != "" {
+			urls = append(urls, status)
+		}
+	}
+	var copies []component.Syndication
+	seen := map[string]bool{}
+	for _, u := range urls {
+		if seen[u] {
+			continue
+		}
+		seen[u] = true
+		name := u
+		if pu, err := url.Parse(u); err == nil && pu.Host != "" {
+			name = strings.TrimPrefix(pu.Host, "www.")
+		}
+		copies = append(copies, component.Syndication{Name: name, URL: u})
+	}
+	return copies
+}
@@ -0,0 +1,111 @@
+package site
+
+import (
+	"sort"
+	"time"
+
+	"be/component"
+)
+
+type TagInfo struct {
+	Tag component.Tag
+	// most recently published first
+	Entries []*Entry
+}
+
+func (t TagInfo) First() time.Time {
+	return t.Entries[len(t.Entries)-1].Data.Meta.Published
+}
+
+func (t TagInfo) Last() time.Time {
+	return t.Entries[0].Data.Meta.Published
+}
+
+// Taxonomy groups the entries by tag, most used tags first.
+// Tags differing only in case are considered the same.
+func (s *Site) Taxonomy() []*TagInfo {
+	bySlug := map[string]*TagInfo{}
+	var tags []*TagInfo
+	for _, e := range s.Entries {
+		for _, t := range e.Data.Tags {
+			info, ok := bySlug[t.Slug()]
+			if !ok {
+				info = &TagInfo{Tag: t}
+				bySlug[t.Slug()] = info
+				tags = append(tags, info)
+			}
+			if len(info.Entries) == 0 || info.Entries[len(info.Entries)-1] != e {
+				info.Entries = append(info.Entries, e)
+			}
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool {
+		if len(tags[i].Entries) != len(tags[j].Entries) {
+			return len(tags[i].Entries) > len(tags[j].Entries)
+		}
+		return tags[i].Tag.Slug() < tags[j].Tag.Slug()
+	})
+	return tags
+}
+
+// buildTags renders an index page for every tag, and an overview of all tags
+// weighted by usage.
+func (s *Site) buildTags() error {
+	tags := s.Taxonomy()
+	cloud := component.TagCloud{}
+	minCount, maxCount := 0, 0
+	if len(tags) > 0 {
+		maxCount, minCount = len(tags[0].Entries), len(tags[len(tags)-1].Entries)
+	}
+	for _, t := range tags {
+		items := []component.PostItem{}
+		for _, e := range t.Entries {
+			items = append(items, s.postItem(e))
+		}
+		p := "tags/" + t.Tag.Slug() + "/index.html"
+		err := s.renderPage(p, &component.EntryData{
+			Title: t.Tag.String(),
+			Layout: "Tag",
+			Content: []component.ContentElement{
+				component.PostList{Title: "Tagged " + t.Tag.String(), Posts: items},
+			},
+		})
+		if err != nil {
+			return err
+		}
+		s.Pages = append(s.Pages, Page{
+			Path: p,
+			LastMod: t.Entries[0].LastMod,
+			ChangeFreq: "monthly",
+		})
+
+		weight := 1
+		if maxCount > minCount {
+			weight = 1 + 4*(len(t.Entries)-minCount)/(maxCount-minCount)
+		}
+		cloud.Tags = append(cloud.Tags, component.TagStat{
+			Tag: t.Tag,
+			Count: len(t.Entries),
+			First: t.First(),
+			Last: t.Last(),
+			Weight: weight,
+		})
+	}
+	// alphabetically is easier to scan, the weight already shows the usage
+	sort.Slice(cloud.Tags, func(i, j int) bool {
+		return cloud.Tags[i].Tag.Slug() < cloud.Tags[j].Tag.Slug()
+	})
+	err := s.renderPage("tags/index.html", &component.EntryData{
+		Title: "Tags",
+		Content: []component.ContentElement{cloud},
+	})
+	if err != nil {
+		return err
+	}
+	s.Pages = append(s.Pages, Page{
+		Path: "tags/index.html",
+		LastMod: s.lastModIn(s.Config.Language),
+		ChangeFreq: "weekly",
+	})
+	return nil
+}
@@ -0,0 +1,137 @@
+package site
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/styles"
+
+	"be/component"
+)
+
+const (
+	themePath = "public/theme.css"
+	// id of the checkbox switching to the other color scheme
+	themeToggleID = "theme-toggle"
+)
+
+// buildTheme generates the stylesheet with the colors of the site: the
+// palettes of the light and dark color scheme, and the colors chroma
+// highlights code blocks with.
+// The dark scheme applies if the reader prefers it. With the toggle enabled,
+// the navigation contains a checkbox that switches to the other scheme
+// without any JavaScript (using :has()), the choice does however not persist
+// between pages.
+func (s *Site) buildTheme() (component.Asset, error) {
+	t := s.Config.Themes
+	light, err := themeCSS(t.Light)
+	if err != nil {
+		return component.Asset{}, err
+	}
+	dark, err := themeCSS(t.Dark)
+	if err != nil {
+		return component.Asset{}, err
+	}
+	toggled := fmt.Sprintf(":root:has(#%s:checked)", themeToggleID)
+
+	sb := &strings.Builder{}
+	sb.WriteString(light(":root"))
+	sb.WriteString("@media (prefers-color-scheme: dark) {\n")
+	sb.WriteString(dark(":root"))
+	if t.Toggle {
+		sb.WriteString(light(toggled))
+	}
+	sb.WriteString("}\n")
+	if t.Toggle {
+		sb.WriteString("@media (prefers-color-scheme: light) {\n")
+		sb.WriteString(dark(toggled))
+		sb.WriteString("}\n")
+	}
+
+	css := sb.String()
+	if s.Config.Minify && !s.Config.Dev {
+		css = minifyCSS(css)
+	}
+	s.Emit(themePath, []byte(css))
+	return component.Asset{Href: "/" + themePath, Integrity: integrity([]byte(css))}, nil
+}
+
+// themeCSS returns a function writing the rules of the theme, scoped to the
+// selector of the root element.
+func themeCSS(t Theme) (func(root string) string, error) {
+	palette, err := os.ReadFile(t.Palette)
+	if err != nil {
+		return nil, err
+	}
+	style := styles.Get(t.Syntax)
+	if t.Syntax != "" && style == styles.Fallback && !strings.EqualFold(t.Syntax, styles.Fallback.Name) {
+		return nil, fmt.Errorf("unknown syntax theme: %s", t.Syntax)
+	}
+	return func(root string) string {
+		sb := &strings.Builder{}
+		fmt.Fprintf(sb, "%s {\n%s}\n", root, palette)
+		sb.WriteString(syntaxCSS(root, style))
+		return sb.String()
+	}, nil
+}
+
+// syntaxCSS translates the chroma style into rules for the token classes of
+// component.CodeBlock.
+// All tokens are first reset to the colors of the code block, so that the
+// rules of one scheme entirely replace those of the other.
+func syntaxCSS(root string, style *chroma.Style) string {
+	sb := &strings.Builder{}
+	bg := style.Get(chroma.Background)
+	fg := bg.Colour
+	if !fg.IsSet() { // contrast with the background
+		fg = chroma.MustParseColour("#000000")
+		if bg.Background.IsSet() && bg.Background.Brightness() < 0.5 {
+			fg = chroma.MustParseColour("#ffffff")
+		}
+	}
+	background := "transparent"
+	if bg.Background.IsSet() {
+		background = bg.Background.String()
+	}
+	fmt.Fprintf(sb, "%s .chroma { color: %s; background-color: %s }\n", root, fg, background)
+	// same specificity as the token rules, the later ones win
+	fmt.Fprintf(sb, "%s .chroma [class] { color: inherit; background-color: transparent; font-weight: normal; font-style: normal; text-decoration: none }\n", root)
+
+	types := make([]chroma.TokenType, 0, len(chroma.StandardTypes))
+	for t := range chroma.StandardTypes {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	for _, t := range types {
+		if t < 0 { // not a token, but the pre wrapper, line numbers, ...
+			continue
+		}
+		if css := styleEntryCSS(style.Get(t).Sub(bg)); css != "" {
+			fmt.Fprintf(sb, "%s .chroma .%s { %s }\n", root, chroma.StandardTypes[t], css)
+		}
+	}
+	return sb.String()
+}
+
+func styleEntryCSS(e chroma.StyleEntry) string {
+	var decls []string
+	if e.Colour.IsSet() {
+		decls = append(decls, "color: "+e.Colour.String())
+	}
+	if e.Background.IsSet() {
+		decls = append(decls, "background-color: "+e.Background.String())
+	}
+	if e.Bold == chroma.Yes {
+		decls = append(decls, "font-weight: bold")
+	}
+	if e.Italic == chroma.Yes {
+		decls = append(decls, "font-style: italic")
+	}
+	if e.Underline == chroma.Yes {
+		decls = append(decls, "text-decoration: underline")
+	}
+	return strings.Join(decls, "; ")
+}
@@ -0,0 +1,243 @@
+package site
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"be/component"
+)
+
+type Webmentions struct {
+	// Jf2 feed of the webmentions received by the site, e.g.
+	// https://webmention.io/api/mentions.jf2?domain=blog.vanloo.ch&token=...
+	// It contains the token, better set it as $BLOG_WEBMENTIONS__FEED.
+	// Fetched during the build, nothing is if empty.
+	Feed string `json:"feed"`
+	// Url webmentions are sent to, advertised by every entry, e.g.
+	// https://webmention.io/blog.vanloo.ch/webmention
+	Endpoint string `json:"endpoint"`
+	// How long fetched webmentions are used before they are fetched again,
+	// e.g. "1h".
+	MaxAge string `json:"max_age"`
+}
+
+// Longest reply (in runes) shown under an entry.
+const maxMentionLength = 300
+
+// Entries fetched per request, the feed is paged through.
+const webmentionPageSize = 100
+
+type (
+	jf2Feed struct {
+		Children []jf2Entry `json:"children"`
+	}
+
+	jf2Entry struct {
+		ID int `json:"wm-id"`
+		// like-of, repost-of, bookmark-of, in-reply-to, mention-of, ...
+		Property string `json:"wm-property"`
+		Target string `json:"wm-target"`
+		Private bool `json:"wm-private"`
+		Received string `json:"wm-received"`
+		URL string `json:"url"`
+		Published string `json:"published"`
+		Author struct {
+			Name string `json:"name"`
+			URL string `json:"url"`
+		} `json:"author"`
+		Content struct {
+			Text string `json:"text"`
+		} `json:"content"`
+	}
+
+	webmentionCache struct {
+		// hash of the feed url, which contains the token
+		Feed string `json:"feed"`
+		Fetched time.Time `json:"fetched"`
+		Entries []jf2Entry `json:"entries"`
+	}
+)
+
+// loadWebmentions fetches the webmentions received by the site (or takes
+// them from the cache, if fetched recently), and sorts them by the entry
+// they are about.
+// If they cannot be fetched, the ones fetched last time are shown.
+func (s *Site) loadWebmentions() error {
+	s.mentions = map[string]component.Mentions{}
+	cfg := s.Config.Webmentions
+	if cfg.Feed == "" {
+		return nil
+	}
+	maxAge := time.Duration(0)
+	if cfg.MaxAge != "" {
+		var err error
+		if maxAge, err = time.ParseDuration(cfg.MaxAge); err != nil {
+			return fmt.Errorf("webmentions: max_age: %w", err)
+		}
+	}
+	sum := sha256.Sum256([]byte(cfg.Feed))
+	feed := hex.EncodeToString(sum[:])
+	p := filepath.Join(s.Config.Cache, "webmentions.json")
+	cache := webmentionCache{}
+	if bs, err := os.ReadFile(p); err == nil {
+		json.Unmarshal(bs, &cache) // fetched again if corrupted
+	}
+	if cache.Feed != feed || time.Since(cache.Fetched) >= maxAge {
+		entries, err := fetchWebmentions(cfg.Feed)
+		if err != nil {
+			slog.Warn("cannot fetch webmentions, using the ones fetched before", "fetched", cache.Fetched, "err", err)
+		} else {
+			cache = webmentionCache{Feed: feed, Fetched: time.Now(), Entries: entries}
+			bs, err := json.Marshal(cache)
+			if err == nil {
+				err = writeCache(p, bs)
+			}
+			if err != nil {
+				slog.Warn("cannot cache webmentions", "err", err)
+			}
+		}
+	}
+	if cache.Feed != feed {
+		return nil // of another feed
+	}
+
+	for _, wm := range cache.Entries {
+		target, err := url.Parse(wm.Target)
+		if wm.Private || err != nil {
+			continue
+		}
+		m := component.Mention{
+			AuthorName: wm.Author.Name,
+			AuthorURL: wm.Author.URL,
+			URL: wm.URL,
+			Content: shorten(strings.Join(strings.Fields(wm.Content.Text), " "), maxMentionLength),
+		}
+		if m.AuthorName == "" {
+			if u, err := url.Parse(cmp.Or(wm.Author.URL, wm.URL)); err == nil {
+				m.AuthorName = u.Host
+			}
+		}
+		for _, t := range []string{wm.Published, wm.Received} {
+			if date, err := time.Parse(time.RFC3339, t); err == nil {
+				m.Published = date
+				break
+			}
+		}
+		k := Canonical(target.Path)
+		ms := s.mentions[k]
+		switch wm.Property {
+		case "like-of":
+			ms.Likes = append(ms.Likes, m)
+		case "repost-of":
+			ms.Reposts = append(ms.Reposts, m)
+		case "bookmark-of":
+			ms.Bookmarks = append(ms.Bookmarks, m)
+		default:
+			ms.Replies = append(ms.Replies, m)
+		}
+		s.mentions[k] = ms
+	}
+	for k, ms := range s.mentions {
+		for _, list := range [][]component.Mention{ms.Likes, ms.Reposts, ms.Bookmarks, ms.Replies} {
+			sort.SliceStable(list, func(i, j int) bool {
+				return list[i].Published.Before(list[j].Published)
+			})
+		}
+		s.mentions[k] = ms
+	}
+	return nil
+}
+
+// mentionsOf the entry, at its url or one of its aliases.
+func (s *Site) mentionsOf(e *Entry) component.Mentions {
+	ms := s.mentions[Canonical(e.Path())]
+	if len(e.Data.Aliases) == 0 {
+		return ms
+	}
+	// appended to, the lists in s.mentions are shared by all entries (which
+	// are rendered in parallel)
+	ms.Likes, ms.Reposts = slices.Clone(ms.Likes), slices.Clone(ms.Reposts)
+	ms.Bookmarks, ms.Replies = slices.Clone(ms.Bookmarks), slices.Clone(ms.Replies)
+	for _, alias := range e.Data.Aliases {
+		old := s.mentions[Canonical(alias)]
+		ms.Likes = append(ms.Likes, old.Likes...)
+		ms.Reposts = append(ms.Reposts, old.Reposts...)
+		ms.Bookmarks = append(ms.Bookmarks, old.Bookmarks...)
+		ms.Replies = append(ms.Replies, old.Replies...)
+	}
+	for _, list := range [][]component.Mention{ms.Likes, ms.Reposts, ms.Bookmarks, ms.Replies} {
+		sort.SliceStable(list, func(i, j int) bool {
+			return list[i].Published.Before(list[j].Published)
+		})
+	}
+	return ms
+}
+
+// fetchWebmentions pages through the feed until a page brings nothing new
+// (feeds that do not support paging return all of them on every page).
+func fetchWebmentions(feed string) ([]jf2Entry, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	seen := map[string]bool{}
+	var entries []jf2Entry
+	for page := 0; ; page++ {
+		u, err := url.Parse(feed)
+		if err != nil {
+			return nil, err
+		}
+		q := u.Query()
+		q.Set("per-page", strconv.Itoa(webmentionPageSize))
+		q.Set("page", strconv.Itoa(page))
+		u.RawQuery = q.Encode()
+		resp, err := client.Get(u.String())
+		if err != nil {
+			return nil, err
+		}
+		var f jf2Feed
+		if resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("%s", resp.Status)
+		} else {
+			err = json.NewDecoder(resp.Body).Decode(&f)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		added := 0
+		for _, wm := range f.Children {
+			k := strconv.Itoa(wm.ID)
+			if wm.ID == 0 {
+				k = wm.Property + " " + wm.URL + " " + wm.Target
+			}
+			if !seen[k] {
+				seen[k] = true
+				entries = append(entries, wm)
+				added++
+			}
+		}
+		if added == 0 || len(f.Children) < webmentionPageSize {
+			return entries, nil
+		}
+	}
+}
+
+// shorten cuts text to at most n runes, marking the cut with an ellipsis.
+func shorten(text string, n int) string {
+	rs := []rune(text)
+	if len(rs) <= n {
+		return text
+	}
+	return strings.TrimSpace(string(rs[:n-1])) + "…"
+}
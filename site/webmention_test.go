@@ -0,0 +1,46 @@
+package site
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"be/component"
+)
+
+func TestMentionsOfAliases(t *testing.T) {
+	likes := make([]component.Mention, 1, 4) // room to append in place
+	likes[0] = component.Mention{URL: "https://example.org/like"}
+	s := New(Config{})
+	s.mentions = map[string]component.Mentions{
+		"/new.html": {Likes: likes},
+		"/old.html": {Likes: []component.Mention{{URL: "https://example.org/old-like"}}},
+	}
+	e := &Entry{Slug: "new", Data: &component.EntryData{Aliases: []string{"old.html"}}}
+	if got := s.mentionsOf(e).Likes; len(got) != 2 {
+		t.Errorf("likes %v, want those of both urls", got)
+	}
+	if stored := s.mentions["/new.html"].Likes; len(stored) != 1 || stored[:2][1].URL != "" {
+		t.Errorf("stored likes changed: %v", stored[:2])
+	}
+}
+
+func TestMentionsOfAliasesOrder(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC)
+	}
+	s := New(Config{})
+	s.mentions = map[string]component.Mentions{
+		"/new.html": {Replies: []component.Mention{{URL: "b", Published: day(2)}, {URL: "d", Published: day(4)}}},
+		"/old.html": {Replies: []component.Mention{{URL: "a", Published: day(1)}, {URL: "c", Published: day(2)}}},
+	}
+	e := &Entry{Slug: "new", Data: &component.EntryData{Aliases: []string{"old.html"}}}
+	var got []string
+	for _, m := range s.mentionsOf(e).Replies {
+		got = append(got, m.URL)
+	}
+	// oldest first, those at the entry's url first if published at the same time
+	if want := []string{"a", "b", "c", "d"}; !slices.Equal(got, want) {
+		t.Errorf("replies %q, want %q", got, want)
+	}
+}
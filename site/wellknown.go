@@ -0,0 +1,80 @@
+package site
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SecurityTxt tells how to report security issues of the site.
+// @from: https://www.rfc-editor.org/rfc/rfc9116
+type SecurityTxt struct {
+	// mailto: or https: urls, in order of preference.
+	Contact []string `json:"contact"`
+	// Date (yyyy-mm-dd) after which the file is not to be trusted anymore.
+	// If empty, a year after the month of the build.
+	Expires string `json:"expires"`
+	// Url of the PGP key to encrypt reports with.
+	Encryption string `json:"encryption"`
+	Policy string `json:"policy"`
+	Acknowledgments string `json:"acknowledgments"`
+	// e.g. "en, de"
+	PreferredLanguages string `json:"preferred_languages"`
+}
+
+const securityTxtPath = ".well-known/security.txt"
+
+// buildWellKnown emits security.txt and the files of Config.WellKnown.
+func (s *Site) buildWellKnown() error {
+	if cfg := s.Config.SecurityTxt; len(cfg.Contact) > 0 {
+		expires := time.Now().UTC()
+		// the same all month, not to change the file with every build
+		expires = time.Date(expires.Year()+1, expires.Month(), 1, 0, 0, 0, 0, time.UTC)
+		if cfg.Expires != "" {
+			t, err := time.Parse(time.DateOnly, cfg.Expires)
+			if err != nil {
+				return fmt.Errorf("security_txt: expires: %w", err)
+			}
+			if t.Before(time.Now()) {
+				s.warnOnce("security.txt", "security.txt has expired", "expires", cfg.Expires)
+			}
+			expires = t
+		}
+		sb := &strings.Builder{}
+		for _, c := range cfg.Contact {
+			fmt.Fprintf(sb, "Contact: %s\n", c)
+		}
+		fmt.Fprintf(sb, "Expires: %s\n", expires.Format(time.RFC3339))
+		field := func(name, value string) {
+			if value != "" {
+				fmt.Fprintf(sb, "%s: %s\n", name, value)
+			}
+		}
+		field("Encryption", cfg.Encryption)
+		field("Acknowledgments", cfg.Acknowledgments)
+		field("Policy", cfg.Policy)
+		field("Preferred-Languages", cfg.PreferredLanguages)
+		field("Canonical", s.URL(securityTxtPath))
+		s.Emit(securityTxtPath, []byte(sb.String()))
+	}
+	var names []string
+	for name := range s.Config.WellKnown {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p := path.Join(".well-known", path.Clean("/"+name))
+		if p == securityTxtPath && len(s.Config.SecurityTxt.Contact) > 0 {
+			return fmt.Errorf("well_known: %s: generated from security_txt", name)
+		}
+		bs, err := os.ReadFile(s.Config.WellKnown[name])
+		if err != nil {
+			return fmt.Errorf("well_known: %w", err)
+		}
+		s.Emit(p, bs)
+	}
+	return nil
+}
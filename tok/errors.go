@@ -0,0 +1,92 @@
+package tok
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Kinds of errors of the tokenizer, compare with errors.Is:
+//
+//	if errors.Is(err, tok.ErrInvalidEscape) { ... }
+var (
+	ErrInvalidEscape = errors.New("invalid escape character")
+	ErrUnterminatedEscape = errors.New("unfinished escape character")
+	ErrUnterminatedRaw = errors.New("unterminated raw text")
+	ErrUnexpectedCharacter = errors.New("unexpected character")
+)
+
+// Error is a problem with the source at a position. The tokenizer, the lexer
+// (package lex) and the evaluator (package component) all report them, with
+// the Kind being one of the Err... variables of these packages.
+//
+//	var e *tok.Error
+//	if errors.As(err, &e) {
+//		fmt.Println(e.Line, e.Column)
+//	}
+type Error struct {
+	Kind error
+	Msg string
+	// Offset (in runes) in the source.
+	Pos int
+	// Filled in by Locate, empty (or 0) if not known.
+	File string
+	Line, Column int
+}
+
+// Errorf creates the error of the kind at pos, the message is the kind if
+// format is empty.
+func Errorf(kind error, pos int, format string, args ...any) *Error {
+	e := &Error{Kind: kind, Pos: pos}
+	if format != "" {
+		e.Msg = fmt.Sprintf(format, args...)
+	}
+	return e
+}
+
+func (e *Error) Error() string {
+	msg := cmp.Or(e.Msg, e.Kind.Error())
+	switch {
+	case e.Line > 0 && e.File != "":
+		return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, msg)
+	case e.Line > 0:
+		return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, msg)
+	case e.File != "":
+		return fmt.Sprintf("%s[%d]: %s", e.File, e.Pos, msg)
+	}
+	return fmt.Sprintf("[%d]: %s", e.Pos, msg)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+// Locate fills in the file, line and column of the errors in err (including
+// the wrapped and joined ones) from the source they were reported for.
+func Locate(err error, file string, src []rune) error {
+	lines := []int{0}
+	for i, r := range src {
+		if r == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+	var locate func(err error)
+	locate = func(err error) {
+		switch err := err.(type) {
+		case *Error:
+			line, _ := slices.BinarySearch(lines, err.Pos+1) // first line starting after pos
+			err.File = file
+			err.Line = max(line, 1)
+			err.Column = err.Pos - lines[err.Line-1] + 1
+		case interface{ Unwrap() []error }:
+			for _, err := range err.Unwrap() {
+				locate(err)
+			}
+		case interface{ Unwrap() error }:
+			locate(err.Unwrap())
+		}
+	}
+	locate(err)
+	return err
+}
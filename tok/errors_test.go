@@ -0,0 +1,55 @@
+package tok
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestTokenizeErrors(t *testing.T) {
+	for _, tt := range []struct {
+		src string
+		kind error
+		pos int
+	}{
+		{`(title a\q)`, ErrInvalidEscape, 8},
+		{`(title a\`, ErrUnterminatedEscape, 8},
+		{"(body\n\\+ raw", ErrUnterminatedRaw, 6},
+		{"((title a))", ErrUnexpectedCharacter, 1},
+		{"(Title a)", ErrUnexpectedCharacter, 1},
+	} {
+		_, err := Tokenize([]rune(tt.src))
+		var e *Error
+		if !errors.As(err, &e) {
+			t.Errorf("%q: got %v, want an *Error", tt.src, err)
+			continue
+		}
+		if !errors.Is(err, tt.kind) || e.Pos != tt.pos {
+			t.Errorf("%q: got %v at %d, want %v at %d", tt.src, e.Kind, e.Pos, tt.kind, tt.pos)
+		}
+	}
+}
+
+func TestLocate(t *testing.T) {
+	src := []rune("first\nsecond ä\nthird")
+	e1 := Errorf(ErrUnexpectedCharacter, 0, "")
+	e2 := Errorf(ErrInvalidEscape, 13, "invalid escape")
+	e3 := Errorf(ErrUnterminatedRaw, 15, "raw")
+	err := Locate(errors.Join(e1, fmt.Errorf("wrapped: %w", e2), e3), "a.be", src)
+	for _, tt := range []struct {
+		e *Error
+		line, column int
+		msg string
+	}{
+		{e1, 1, 1, "a.be:1:1: unexpected character"},
+		{e2, 2, 8, "a.be:2:8: invalid escape"},
+		{e3, 3, 1, "a.be:3:1: raw"},
+	} {
+		if tt.e.Line != tt.line || tt.e.Column != tt.column || tt.e.Error() != tt.msg {
+			t.Errorf("got %d:%d %q, want %d:%d %q", tt.e.Line, tt.e.Column, tt.e.Error(), tt.line, tt.column, tt.msg)
+		}
+	}
+	if !errors.Is(err, ErrInvalidEscape) {
+		t.Error("kind of a wrapped error lost")
+	}
+}
@@ -33,9 +33,13 @@
 // joined together (newline replaced by space).
 // Multiple spaces are removed, so that only a single space remains.
 // The only exception are raw strings (of the form '\+ ... \+').
+//
+// A Tokenizer tokenizes its input once, Tokenize (the function) is safe for
+// concurrent use.
 package tok
 
 import (
+	"errors"
 	"fmt"
 	"log"
 )
@@ -64,12 +68,7 @@ type (
 		tokens []Token
 		state tokFunc
 		err error
-	}
-
-	TokenError struct {
-		Msg string
-		Pos int
-		FileName string
+		used bool
 	}
 )
 
@@ -80,7 +79,19 @@ func NewTokenizer(bs []rune) *Tokenizer {
 	}
 }
 
+// Tokenize splits the source into tokens.
+func Tokenize(bs []rune) ([]Token, error) {
+	return NewTokenizer(bs).Tokenize()
+}
+
+var errUsed = errors.New("tokenizer already used")
+
+// Tokenize the input of the tokenizer, it can only be called once.
 func (t *Tokenizer) Tokenize() ([]Token, error) {
+	if t.used {
+		return nil, errUsed
+	}
+	t.used = true
 	t.state = t.tokTextOrForm // initial state [:init:]
 	for t.state != nil {
 		t.skipWhitespace()
@@ -110,6 +121,7 @@ func (t *Tokenizer) tokText() tokFunc { // parse text
 		textEnd = t.pos
 		lastPos = textEnd
 		quoted = false
+		rawStart = 0
 		parsedText = ""
 	)
 	for textEnd < t.l && ((t.bs[textEnd] != ')' && t.bs[textEnd] != '(') || quoted) {
@@ -156,15 +168,16 @@ func (t *Tokenizer) tokText() tokFunc { // parse text
 						lastPos = textEnd + 1 // past backslash
 						textEnd += 2          // past escaped char
 					case '+':
+						rawStart = textEnd
 						parsedText += string(t.bs[lastPos:textEnd])
 						lastPos = textEnd + 2 // past escaped char
 						textEnd += 2          // past escaped char
 						quoted = !quoted
 					default:
-						return t.tokError(t.NewTokenError(fmt.Sprintf("invalid escape character: `%s`", string(esc))))
+						return t.tokError(Errorf(ErrInvalidEscape, textEnd, "invalid escape character: `%s`", string(esc)))
 					}
 				} else {
-					return t.tokError(t.NewTokenError("unfinished escape character (did you mean `\\`?)"))
+					return t.tokError(Errorf(ErrUnterminatedEscape, textEnd, "unfinished escape character (did you mean `\\`?)"))
 				}
 			} else if t.bs[textEnd] == '~' {
 				parsedText += string(t.bs[lastPos:textEnd])
@@ -190,6 +203,9 @@ func (t *Tokenizer) tokText() tokFunc { // parse text
 			}
 		}
 	}
+	if quoted {
+		return t.tokError(Errorf(ErrUnterminatedRaw, rawStart, "unterminated raw text (missing closing `\\+`)"))
+	}
 	parsedText += string(t.bs[lastPos:textEnd])
 	t.tokens = append(t.tokens, Token{
 		Type: TypeText,
@@ -215,7 +231,7 @@ func (t *Tokenizer) tokForm() tokFunc { // parse form start
 func (t *Tokenizer) tokNilOrAtom() tokFunc {
 	r := t.bs[t.pos]
 	if r == '(' {
-		return t.tokError(t.NewTokenError("cannot start form / expected atom or nil"))
+		return t.tokError(Errorf(ErrUnexpectedCharacter, t.pos, "cannot start form / expected atom or nil"))
 	}
 	if r == ')' {
 		return t.tokNil
@@ -223,7 +239,7 @@ func (t *Tokenizer) tokNilOrAtom() tokFunc {
 	if isAtomChar(r) {
 		return t.tokAtom
 	}
-	return t.tokError(t.NewTokenError(fmt.Sprintf("invalid character: `%s` / expected nil or atom", string(r))))
+	return t.tokError(Errorf(ErrUnexpectedCharacter, t.pos, "invalid character: `%s` / expected nil or atom", string(r)))
 }
 
 func (t *Tokenizer) tokNil() tokFunc { // parse form end
@@ -315,18 +331,6 @@ func isAtomChar(r rune) bool {
 	return isAlphaLower(r) || isNum(r)
 }
 
-func (t *Tokenizer) NewTokenError(msg string) TokenError {
-	return TokenError{
-		Msg: msg,
-		Pos: t.pos,
-		FileName: "@todo: implement",
-	}
-}
-
-func (e TokenError) Error() string {
-	return fmt.Sprintf("%s[%d]: %s", e.FileName, e.Pos, e.Msg)
-}
-
 func (t Token) String() string {
 	switch (t.Type) {
 	case TypeFormStart:
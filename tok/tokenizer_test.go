@@ -0,0 +1,48 @@
+package tok
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+)
+
+const source = `(title Hello World)
+(body
+Some text (link (url /x) (text a link)).
+
+\+ raw (text) \+
+)`
+
+func TestTokenizerSingleUse(t *testing.T) {
+	tz := NewTokenizer([]rune(source))
+	if _, err := tz.Tokenize(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tz.Tokenize(); !errors.Is(err, errUsed) {
+		t.Errorf("second Tokenize: got %v, want %v", err, errUsed)
+	}
+}
+
+func TestTokenizeConcurrent(t *testing.T) {
+	want, err := Tokenize([]rune(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := Tokenize([]rune(source))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		}()
+	}
+	wg.Wait()
+}